@@ -28,6 +28,7 @@ func (app *Application) SetName(name string) {
 // Initializes the application bootstrap.
 func (app *Application) Initialize(bootstrap *core.Bootstrap) {
 	bootstrap.AddCommand(&CheckCommand{})
+	bootstrap.AddCommand(&DumpConfigCommand{})
 	bootstrap.AddCommand(&ServerCommand{})
 }
 
@@ -1,53 +1,69 @@
 /*
-Package assets provides a bundle for serving static asset files.
+Package assets provides a bundle serving static files from a directory.
 */
 package assets
 
 import (
+	"bytes"
+	"fmt"
+	"html"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
 
-	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
 )
 
 const (
-	assetsLoggerName = "gomelon/assets"
+	defaultIndexFile    = "index.html"
+	defaultCacheControl = "public, max-age=3600"
 )
 
-// Bundle serves static asset files.
+// Bundle serves files in Root directory under URL Prefix of the application.
 type Bundle struct {
-	dir     string
-	urlPath string
+	// Root is the directory of static files.
+	Root string
+	// Prefix is the URL path which files are served under, e.g. "/assets/".
+	// Leading and trailing slashes are added if missing.
+	Prefix string
+	// IndexFile is served for directory requests. Default is index.html.
+	// Files in the directory are listed if it does not have the index file.
+	IndexFile string
+	// CacheControl is the Cache-Control header of responses.
+	// Default is "public, max-age=3600".
+	CacheControl string
 }
 
-// AssetsBundle implements Bundle interface
 var _ core.Bundle = (*Bundle)(nil)
 
-// NewAssetsBundle allocates and returns a new AssetsBundle.
-// urlPath must always start with "/".
-func NewBundle(dir, urlPath string) *Bundle {
+// NewBundle allocates and returns a new Bundle.
+func NewBundle(root, prefix string) *Bundle {
 	return &Bundle{
-		dir:     dir,
-		urlPath: urlPath,
+		Root:         root,
+		Prefix:       prefix,
+		IndexFile:    defaultIndexFile,
+		CacheControl: defaultCacheControl,
 	}
 }
 
-func (bundle *Bundle) Initialize(bootstrap *core.Bootstrap) {
-	// Do nothing
+func (b *Bundle) Initialize(bootstrap *core.Bootstrap) {
 }
 
-// Run registers current AssetsBundle to the server in the given environment.
-func (bundle *Bundle) Run(_ interface{}, env *core.Environment) error {
-	gol.GetLogger(assetsLoggerName).Info("registering AssetsBundle for path %s", bundle.urlPath)
-
-	// Add slashes if necessary
-	p := addSlashes(bundle.urlPath)
-	handler := http.FileServer(http.Dir(bundle.dir))
-	// Strip path prefix if needed
-	if p != "/" {
-		handler = http.StripPrefix(p, handler)
+// Run registers the file handler to the application server handler.
+func (b *Bundle) Run(conf interface{}, env *core.Environment) error {
+	prefix := addSlashes(b.Prefix)
+	handler := &fileHandler{
+		root:         http.Dir(b.Root),
+		prefix:       prefix,
+		indexFile:    b.IndexFile,
+		cacheControl: b.CacheControl,
 	}
-	env.Server.ServerHandler.Handle("GET", p+"*", handler)
+	env.Server.ServerHandler.Handle("GET", prefix+"*", handler)
+	env.Server.ServerHandler.Handle("HEAD", prefix+"*", handler)
+	env.Server.LogEndpoint("GET", prefix+"*", handler)
 	return nil
 }
 
@@ -64,3 +80,103 @@ func addSlashes(p string) string {
 	}
 	return p
 }
+
+// fileHandler serves files with ETag and Cache-Control headers.
+type fileHandler struct {
+	root         http.FileSystem
+	prefix       string
+	indexFile    string
+	cacheControl string
+}
+
+func (h *fileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, h.prefix)
+	// Paths containing ".." are rejected instead of being cleaned so that
+	// files outside the prefix can not be accessed.
+	for _, s := range strings.Split(name, "/") {
+		if s == ".." {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	name = path.Clean("/" + name)
+	f, err := h.root.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if info.IsDir() {
+		// Relative links in the index and listing require a trailing slash.
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+		index, indexInfo := h.openIndex(name)
+		if index == nil {
+			dirList(w, f)
+			return
+		}
+		defer index.Close()
+		f, info = index, indexInfo
+	}
+	if h.cacheControl != "" {
+		w.Header().Set("Cache-Control", h.cacheControl)
+	}
+	w.Header().Set("ETag", etag(info))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// openIndex returns the index file in the directory or nil if it does not
+// exist.
+func (h *fileHandler) openIndex(dir string) (http.File, os.FileInfo) {
+	if h.indexFile == "" {
+		return nil, nil
+	}
+	f, err := h.root.Open(path.Join(dir, h.indexFile))
+	if err != nil {
+		return nil, nil
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil
+	}
+	return f, info
+}
+
+// dirList writes links to files in the directory.
+func dirList(w http.ResponseWriter, f http.File) {
+	files, err := f.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	var buf bytes.Buffer
+	buf.WriteString("<pre>\n")
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() {
+			name += "/"
+		}
+		// "./" prevents names containing ":" from being parsed as schemes.
+		link := url.URL{Path: "./" + name}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(name))
+	}
+	buf.WriteString("</pre>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// etag is computed from modification time and size of the file.
+func etag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
@@ -17,7 +17,7 @@ func TestAssetsBundle(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer os.Remove(dir)
+	defer os.RemoveAll(dir)
 	// Setup environment
 	env := core.NewEnvironment()
 	handler := server.NewHandler()
@@ -58,4 +58,91 @@ func TestAssetsBundle(t *testing.T) {
 	if string(body) != "assets bundle" {
 		t.Fatalf("unexpected response body: %s", body)
 	}
+	if res.Header.Get("ETag") == "" || res.Header.Get("Cache-Control") != defaultCacheControl {
+		t.Fatalf("unexpected headers: %v", res.Header)
+	}
+	testBundleFiles(t, dir)
+}
+
+// testBundleFiles tests files and directories served by bundles with
+// different prefixes.
+func testBundleFiles(t *testing.T, dir string) {
+	files := map[string]string{
+		"secret.txt":               "secret",
+		"public/app.js":            "app",
+		"public/index.html":        "index",
+		"public/css/style.css":     "style",
+		"public/css/empty/ignored": "",
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var handler *server.Handler
+	// Prefixes are normalized.
+	for _, prefix := range []string{"assets", "/assets", "/assets/"} {
+		env := core.NewEnvironment()
+		handler = server.NewHandler()
+		env.Server.ServerHandler = handler
+		if err := NewBundle(filepath.Join(dir, "public"), prefix).Run(nil, env); err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			path string
+			code int
+			body string
+		}{
+			{"/assets/app.js", http.StatusOK, "app"},
+			{"/assets/", http.StatusOK, "index"},
+			{"/assets/css/style.css", http.StatusOK, "style"},
+			{"/assets/css/", http.StatusOK, "<pre>\n<a href=\"./empty/\">empty/</a>\n<a href=\"./style.css\">style.css</a>\n</pre>\n"},
+			{"/assets/css", http.StatusMovedPermanently, ""},
+			{"/assets/missing.js", http.StatusNotFound, ""},
+			{"/assets/../secret.txt", http.StatusNotFound, ""},
+			{"/assets/css/../../secret.txt", http.StatusNotFound, ""},
+		}
+		for _, test := range tests {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "http://localhost"+test.path, nil)
+			r.URL.Path = test.path
+			handler.ServeHTTP(w, r)
+			if w.Code != test.code {
+				t.Fatalf("unexpected code %v for %v", w.Code, test.path)
+			}
+			if test.code == http.StatusOK && w.Body.String() != test.body {
+				t.Fatalf("unexpected body %v for %v", w.Body.String(), test.path)
+			}
+		}
+	}
+
+	// Not modified
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/assets/app.js", nil)
+	handler.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	w = httptest.NewRecorder()
+	r.Header.Set("If-None-Match", etag)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+
+	// Empty prefix serves from the root.
+	env := core.NewEnvironment()
+	handler = server.NewHandler()
+	env.Server.ServerHandler = handler
+	if err := NewBundle(filepath.Join(dir, "public"), "").Run(nil, env); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/app.js", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "app" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/configuration"
 	"github.com/goburrow/gomelon/core"
 	"github.com/goburrow/gomelon/logging"
 	"github.com/goburrow/gomelon/metrics"
@@ -37,6 +38,12 @@ func (c *Configuration) MetricsFactory() core.MetricsFactory {
 	return &c.Metrics
 }
 
+// Validate checks invariants of the configuration which can not be expressed
+// with field tags, e.g. https connectors require certificate files.
+func (c *Configuration) Validate() error {
+	return c.Server.Validate()
+}
+
 // ConfigurationCommand parses configuration.
 type ConfigurationCommand struct {
 	// Configuration is the original configuration provided by application.
@@ -55,6 +62,14 @@ func (command *ConfigurationCommand) Run(bootstrap *core.Bootstrap) error {
 		gol.GetLogger(configurationLoggerName).Error("configuration is invalid: %v", err)
 		return err
 	}
+	if v, ok := command.Configuration.(interface {
+		Validate() error
+	}); ok {
+		if err = v.Validate(); err != nil {
+			gol.GetLogger(configurationLoggerName).Error("configuration is invalid: %v", err)
+			return err
+		}
+	}
 	// Configuration provided must implement core.Configuration interface.
 	var ok bool
 	if command.configuration, ok = command.Configuration.(core.Configuration); !ok {
@@ -66,6 +81,8 @@ func (command *ConfigurationCommand) Run(bootstrap *core.Bootstrap) error {
 	return nil
 }
 
+// CheckCommand parses and validates the configuration without starting the
+// server. It returns an error if the configuration is invalid.
 type CheckCommand struct {
 	ConfigurationCommand
 }
@@ -82,10 +99,44 @@ func (c *CheckCommand) Description() string {
 
 func (c *CheckCommand) Run(bootstrap *core.Bootstrap) error {
 	if err := c.ConfigurationCommand.Run(bootstrap); err != nil {
+		fmt.Fprintf(stderr, "Configuration is invalid: %v\n", err)
 		return err
 	}
-
-	gol.GetLogger(configurationLoggerName).Debug("configuration: %+v", c.ConfigurationCommand.Configuration)
-	fmt.Println("Configuration is OK")
+	fmt.Fprintln(stdout, "Configuration is valid")
 	return nil
 }
+
+// DumpConfigCommand prints the parsed configuration in the format of the
+// configuration file. Values of fields tagged `secret:"true"` are redacted.
+type DumpConfigCommand struct {
+	ConfigurationCommand
+}
+
+var _ core.Command = (*DumpConfigCommand)(nil)
+
+func (c *DumpConfigCommand) Name() string {
+	return "dump-config"
+}
+
+func (c *DumpConfigCommand) Description() string {
+	return "prints the effective configuration with secrets redacted"
+}
+
+func (c *DumpConfigCommand) Run(bootstrap *core.Bootstrap) error {
+	if err := c.ConfigurationCommand.Run(bootstrap); err != nil {
+		fmt.Fprintf(stderr, "Configuration is invalid: %v\n", err)
+		return err
+	}
+	var b []byte
+	var err error
+	if dumper, ok := bootstrap.ConfigurationFactory.(core.ConfigurationDumper); ok {
+		b, err = dumper.Dump(bootstrap.Arguments[1])
+	} else {
+		b, err = configuration.Dump(bootstrap.Arguments[1], c.ConfigurationCommand.Configuration)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(b)
+	return err
+}
@@ -4,12 +4,20 @@ Package configuration provides JSON and YAML support for gomelon configuration.
 package configuration
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
@@ -19,15 +27,33 @@ import (
 
 const (
 	loggerName = "gomelon/configuration"
+
+	// httpTimeout is the timeout of fetching configuration from a URL.
+	httpTimeout = 30 * time.Second
+
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// For testing
+var (
+	stdin io.Reader = os.Stdin
 )
 
 // Factory implements gomelon.ConfigurationFactory interface.
 type Factory struct {
 	// Configuration is the type/pointer of application configuration.
 	Configuration interface{}
+
+	// content is the configuration file in JSON.
+	content []byte
+	// sections are top level values of the configuration in JSON.
+	sections map[string]json.RawMessage
 }
 
 var _ core.ConfigurationFactory = (*Factory)(nil)
+var _ core.ConfigurationSectionDecoder = (*Factory)(nil)
+var _ core.ConfigurationDumper = (*Factory)(nil)
 
 // BuildConfiguration parse config file and returns the factory configuration.
 func (factory *Factory) Build(bootstrap *core.Bootstrap) (interface{}, error) {
@@ -35,41 +61,208 @@ func (factory *Factory) Build(bootstrap *core.Bootstrap) (interface{}, error) {
 		gol.GetLogger(loggerName).Error("configuration file is not specified in command arguments: %v", bootstrap.Arguments)
 		return nil, errors.New("configuration: no file specified")
 	}
-	if err := Unmarshal(bootstrap.Arguments[1], factory.Configuration); err != nil {
+	path := bootstrap.Arguments[1]
+	content, err := unmarshal(path, factory.Configuration)
+	if err != nil {
+		gol.GetLogger(loggerName).Error("%v", err)
+		return nil, err
+	}
+	factory.content = content
+	factory.sections = nil
+	if err = json.Unmarshal(content, &factory.sections); err != nil {
+		err = fmt.Errorf("configuration: could not parse %s: %v", path, err)
 		gol.GetLogger(loggerName).Error("%v", err)
 		return nil, err
 	}
 	return factory.Configuration, nil
 }
 
-// Unmarshal decodes the given file to output type.
+// DecodeSection decodes the top level value of key in the configuration file
+// to output. Keys are matched case-insensitively. Output is not changed if
+// the key does not exist. Build must be called first.
+func (factory *Factory) DecodeSection(key string, output interface{}) error {
+	for k, v := range factory.sections {
+		if strings.EqualFold(k, key) {
+			if err := json.Unmarshal(v, output); err != nil {
+				return fmt.Errorf("configuration: could not parse %s: %v", key, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Unmarshal decodes the given file to output type. Placeholders ${VAR} and
+// ${VAR:-default} in the file are replaced by environment variables. In YAML
+// files, they are only replaced in string values and not in comments.
+// The configuration is read from stdin if path is "-" and fetched over HTTP
+// if path is an http or https URL.
 func Unmarshal(path string, output interface{}) error {
-	f, err := os.Open(path)
+	_, err := unmarshal(path, output)
+	return err
+}
+
+// unmarshal decodes the given file to output and returns its content in JSON.
+func unmarshal(path string, output interface{}) ([]byte, error) {
+	content, format, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case formatJSON:
+		if content, err = expandEnv(content, content); err == nil {
+			err = unmarshalJSON(content, output)
+		}
+	case formatYAML:
+		// Placeholders are replaced after converting to JSON so that values
+		// can not change the YAML structure and comments are ignored.
+		var source []byte
+		if source, err = yaml.YAMLToJSON(content); err == nil {
+			if source, err = expandEnv(source, content); err == nil {
+				// JSON is also YAML so it still gets field type conversion.
+				if err = yaml.Unmarshal(source, output); err == nil {
+					content = source
+				}
+			}
+		}
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("configuration: could not parse %s: %v", path, err)
 	}
-	defer f.Close()
+	return content, nil
+}
 
-	ext := filepath.Ext(path)
+// read returns content and format of the configuration at path.
+func read(path string) ([]byte, string, error) {
+	if path == "-" {
+		content, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, "", fmt.Errorf("configuration: could not read stdin: %v", err)
+		}
+		return content, detectFormat(content), nil
+	}
+	if isURL(path) {
+		return fetch(path)
+	}
+	format, err := fileFormat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, format, nil
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetch gets the configuration from rawurl. The format is selected by the
+// content type of the response or the extension of the URL path.
+func fetch(rawurl string) ([]byte, string, error) {
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Get(rawurl)
+	if err != nil {
+		return nil, "", fmt.Errorf("configuration: could not fetch %s: %v", rawurl, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("configuration: could not fetch %s: %s", rawurl, resp.Status)
+	}
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("configuration: could not fetch %s: %v", rawurl, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		return content, formatJSON, nil
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return content, formatYAML, nil
+	}
+	if u, err := url.Parse(rawurl); err == nil {
+		if format, err := fileFormat(u.Path); err == nil {
+			return content, format, nil
+		}
+	}
+	return content, detectFormat(content), nil
+}
+
+// fileFormat returns the format by extension of the file name.
+func fileFormat(name string) (string, error) {
+	ext := filepath.Ext(name)
 	switch ext {
 	case ".json", ".js":
-		return unmarshalJSON(f, output)
+		return formatJSON, nil
 	case ".yaml", ".yml":
-		return unmarshalYAML(f, output)
+		return formatYAML, nil
 	default:
-		return fmt.Errorf("configuration: unsupported file type %s", ext)
+		return "", fmt.Errorf("configuration: unsupported file type %s", ext)
+	}
+}
+
+// detectFormat returns JSON if content is a JSON object, otherwise YAML.
+func detectFormat(content []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return formatJSON
+	}
+	return formatYAML
+}
+
+func unmarshalJSON(content []byte, output interface{}) error {
+	err := json.Unmarshal(content, output)
+	// Offset is not helpful for users so convert it to line number.
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return fmt.Errorf("line %d: %v", lineNumber(content, e.Offset), err)
+	case *json.UnmarshalTypeError:
+		return fmt.Errorf("line %d: %v", lineNumber(content, e.Offset), err)
 	}
+	return err
 }
 
-func unmarshalJSON(f *os.File, output interface{}) error {
-	decoder := json.NewDecoder(f)
-	return decoder.Decode(output)
+// lineNumber returns line number of the offset in content.
+func lineNumber(content []byte, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return bytes.Count(content[:offset], []byte{'\n'}) + 1
 }
 
-func unmarshalYAML(f *os.File, output interface{}) error {
-	content, err := ioutil.ReadAll(f)
+// envPattern matches ${VAR} and ${VAR:-default}.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces placeholders in JSON content with escaped values of
+// environment variables. It returns error if a variable is not set and has no
+// default. Line numbers in the error refer to the original file source.
+func expandEnv(content, source []byte) ([]byte, error) {
+	var err error
+	result := envPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		groups := envPattern.FindSubmatch(match)
+		name := string(groups[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			if groups[2] == nil {
+				if err == nil {
+					err = fmt.Errorf("line %d: environment variable %s is not set",
+						lineNumber(source, int64(bytes.Index(source, match))), name)
+				}
+				return match
+			}
+			value = string(groups[3])
+		}
+		return []byte(escapeJSON(value))
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return yaml.Unmarshal(content, output)
+	return result, nil
+}
+
+// escapeJSON escapes the value to be placed in a JSON string.
+func escapeJSON(value string) string {
+	b, _ := json.Marshal(value)
+	return string(b[1 : len(b)-1])
 }
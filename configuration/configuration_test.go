@@ -1,9 +1,17 @@
 package configuration
 
 import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/goburrow/gomelon/core"
+	"github.com/goburrow/polytype"
 )
 
 type configuration struct {
@@ -23,6 +31,8 @@ type connectorConfiguration struct {
 
 	CertFile string
 	KeyFile  string
+
+	ReadTimeout string
 }
 
 type loggingConfiguration struct {
@@ -71,8 +81,9 @@ func testFactory(t *testing.T, bootstrap *core.Bootstrap) {
 		t.Fatalf("Invalid ApplicationConnectors: %+v", config.Server.ApplicationConnectors)
 	}
 	adminConnector1 := connectorConfiguration{
-		Type: "http",
-		Addr: ":8081",
+		Type:        "http",
+		Addr:        ":8081",
+		ReadTimeout: "30s",
 	}
 	if len(config.Server.AdminConnectors) != 1 ||
 		config.Server.AdminConnectors[0] != adminConnector1 {
@@ -87,3 +98,315 @@ func testFactory(t *testing.T, bootstrap *core.Bootstrap) {
 		t.Fatalf("Invalid Metrics: %+v", config.Metrics)
 	}
 }
+
+func TestUnmarshalError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file     string
+		content  string
+		expected string
+	}{
+		{"config.json", "{\n  \"server\": {\n    \"applicationConnectors\": [,]\n  }\n}", "line 3: "},
+		{"config.json", "{\n  \"logging\": {\n    \"level\": 1\n  }\n}", "line 3: "},
+		{"config.yaml", "server:\n  applicationConnectors:\n   - type: http\n  - type: https\n", "line 3: "},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.file)
+		if err = ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err = Unmarshal(path, &configuration{})
+		if err == nil {
+			t.Fatalf("error expected for %s", test.content)
+		}
+		if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("GOMELON_TEST_ADDR", ":9090")
+	os.Setenv("GOMELON_TEST_KEY", `/tmp/"key"`)
+	os.Unsetenv("GOMELON_TEST_UNSET")
+	defer os.Unsetenv("GOMELON_TEST_ADDR")
+	defer os.Unsetenv("GOMELON_TEST_KEY")
+
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := `{
+  "server": {
+    "applicationConnectors": [
+      {
+        "type": "https",
+        "addr": "${GOMELON_TEST_ADDR}",
+        "certFile": "${GOMELON_TEST_UNSET:-/tmp/cert}",
+        "keyFile": "${GOMELON_TEST_KEY}"
+      }
+    ]
+  }
+}`
+	path := filepath.Join(dir, "config.json")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := &configuration{}
+	if err = Unmarshal(path, config); err != nil {
+		t.Fatal(err)
+	}
+	expected := connectorConfiguration{
+		Type:     "https",
+		Addr:     ":9090",
+		CertFile: "/tmp/cert",
+		KeyFile:  `/tmp/"key"`,
+	}
+	if len(config.Server.ApplicationConnectors) != 1 || config.Server.ApplicationConnectors[0] != expected {
+		t.Fatalf("unexpected connectors %+v", config.Server.ApplicationConnectors)
+	}
+
+	// Values must not change YAML structure.
+	os.Setenv("GOMELON_TEST_ADDR", "localhost:8080 # comment\n  type: http")
+	content = "# ${GOMELON_TEST_UNSET}\nserver:\n  applicationConnectors:\n    - type: https\n      addr: ${GOMELON_TEST_ADDR}\n      keyFile: \"${GOMELON_TEST_KEY}\"\n"
+	path = filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config = &configuration{}
+	if err = Unmarshal(path, config); err != nil {
+		t.Fatal(err)
+	}
+	expected = connectorConfiguration{
+		Type:    "https",
+		Addr:    "localhost:8080 # comment\n  type: http",
+		KeyFile: `/tmp/"key"`,
+	}
+	if len(config.Server.ApplicationConnectors) != 1 || config.Server.ApplicationConnectors[0] != expected {
+		t.Fatalf("unexpected connectors %+v", config.Server.ApplicationConnectors)
+	}
+
+	content = "logging:\n  level: ${GOMELON_TEST_UNSET}\n"
+	path = filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = Unmarshal(path, &configuration{})
+	if err == nil || !strings.Contains(err.Error(), "line 2: environment variable GOMELON_TEST_UNSET is not set") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type dumpConfiguration struct {
+	dumpEmbedded
+
+	Name     string
+	Password string `secret:"true"`
+	Admins   []dumpAdmin
+	Labels   map[string]string `json:"tags"`
+	Ignored  string            `json:"-"`
+}
+
+type dumpEmbedded struct {
+	TCPNoDelay bool
+}
+
+type dumpAdmin struct {
+	Username string
+	Password string `secret:"true"`
+}
+
+func TestDump(t *testing.T) {
+	c := &dumpConfiguration{
+		dumpEmbedded: dumpEmbedded{TCPNoDelay: true},
+		Name:         "test",
+		Password:     "secret",
+		Admins:       []dumpAdmin{{"admin", "secret"}},
+		Labels:       map[string]string{"a": "b"},
+		Ignored:      "ignored",
+	}
+	b, err := Dump("config.yaml", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `admins:
+- password: '****'
+  username: admin
+name: test
+password: '****'
+tags:
+  a: b
+tcpNoDelay: true
+`
+	if string(b) != expected {
+		t.Fatalf("unexpected yaml:\n%s", b)
+	}
+	b, err = Dump("config.json", c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"password": "****"`) || strings.Contains(string(b), "secret") {
+		t.Fatalf("unexpected json:\n%s", b)
+	}
+	if _, err = Dump("config.toml", c); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestUnmarshalStdin(t *testing.T) {
+	defer func() {
+		stdin = os.Stdin
+	}()
+	for _, file := range []string{"configuration_test.json", "configuration_test.yaml"} {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdin = bytes.NewReader(content)
+		bootstrap := core.Bootstrap{
+			Arguments: []string{"server", "-"},
+		}
+		testFactory(t, &bootstrap)
+	}
+}
+
+func TestUnmarshalURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config":
+			w.Header().Set("Content-Type", "application/json")
+			http.ServeFile(w, r, "configuration_test.json")
+		case "/config.yaml":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			http.ServeFile(w, r, "configuration_test.yaml")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/config", "/config.yaml"} {
+		bootstrap := core.Bootstrap{
+			Arguments: []string{"server", server.URL + path},
+		}
+		testFactory(t, &bootstrap)
+	}
+
+	err := Unmarshal(server.URL+"/unknown", &configuration{})
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	url := server.URL + "/config"
+	server.Close()
+	err = Unmarshal(url, &configuration{})
+	if err == nil || !strings.HasPrefix(err.Error(), "configuration: could not fetch "+url+": ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type databaseConfiguration struct {
+	URL      string
+	MaxConns int
+}
+
+type databaseBundle struct {
+	configuration *databaseConfiguration
+}
+
+func (b *databaseBundle) Initialize(*core.Bootstrap) {}
+
+func (b *databaseBundle) ConfigurationKey() string {
+	return "database"
+}
+
+func (b *databaseBundle) Configuration() interface{} {
+	return &databaseConfiguration{MaxConns: 10}
+}
+
+func (b *databaseBundle) Run(configuration interface{}, environment *core.Environment) error {
+	b.configuration = configuration.(*databaseConfiguration)
+	return nil
+}
+
+func TestConfiguredBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	content := "logging:\n  level: INFO\ndatabase:\n  url: postgres://localhost/test\n"
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bundle := &databaseBundle{}
+	bootstrap := core.NewBootstrap(nil)
+	bootstrap.Arguments = []string{"server", path}
+	bootstrap.ConfigurationFactory = &Factory{Configuration: &configuration{}}
+	bootstrap.AddBundle(bundle)
+
+	c, err := bootstrap.ConfigurationFactory.Build(bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.(*configuration).Logging.Level != "INFO" {
+		t.Fatalf("unexpected configuration %+v", c)
+	}
+	if err = bootstrap.Run(c, core.NewEnvironment()); err != nil {
+		t.Fatal(err)
+	}
+	expected := databaseConfiguration{
+		URL:      "postgres://localhost/test",
+		MaxConns: 10,
+	}
+	if bundle.configuration == nil || *bundle.configuration != expected {
+		t.Fatalf("unexpected bundle configuration %+v", bundle.configuration)
+	}
+}
+
+type dumpPolytypeConfiguration struct {
+	Database dumpDatabase
+}
+
+type dumpDatabase struct {
+	polytype.Type
+}
+
+type dumpPostgres struct {
+	URL      string
+	Password string `secret:"true"`
+}
+
+func TestFactoryDump(t *testing.T) {
+	polytype.Register("postgres", func() interface{} { return &dumpPostgres{} })
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	content := "database:\n  type: postgres\n  url: localhost\n  password: secret\n"
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	factory := &Factory{Configuration: &dumpPolytypeConfiguration{}}
+	if _, err = factory.Build(&core.Bootstrap{Arguments: []string{"dump-config", path}}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := factory.Dump(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "database:\n  password: '****'\n  type: postgres\n  url: localhost\n"
+	if string(b) != expected {
+		t.Fatalf("unexpected yaml:\n%s", b)
+	}
+}
@@ -0,0 +1,230 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+	"github.com/goburrow/polytype"
+)
+
+// redactedValue replaces values of secret fields.
+const redactedValue = "****"
+
+var polytypeType = reflect.TypeOf(polytype.Type{})
+
+// polytypeMap is a redacted struct with a polytype.Type value, which needs
+// the type name to be decoded again.
+type polytypeMap map[string]interface{}
+
+// Dump encodes the configuration in the format of the file at path, i.e. JSON
+// or YAML. Values of fields tagged `secret:"true"` are replaced by "****".
+// YAML is used if the format can not be told from path, e.g. stdin.
+// Type names of polymorphic values are not known so they are not included,
+// see Factory.Dump.
+func Dump(path string, configuration interface{}) ([]byte, error) {
+	return dump(path, configuration, nil)
+}
+
+// Dump encodes the configuration built by Build like Dump. Type names of
+// polymorphic values, e.g. server type, are taken from the configuration
+// file.
+func (factory *Factory) Dump(path string) ([]byte, error) {
+	return dump(path, factory.Configuration, factory.content)
+}
+
+// dump encodes the configuration with type names from source in JSON.
+func dump(path string, configuration interface{}, source []byte) ([]byte, error) {
+	value := redact(reflect.ValueOf(configuration))
+	if source != nil {
+		var sourceValue interface{}
+		if err := json.Unmarshal(source, &sourceValue); err != nil {
+			return nil, err
+		}
+		addTypes(value, sourceValue)
+	}
+	format, err := dumpFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == formatJSON {
+		b, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+	return yaml.Marshal(value)
+}
+
+func dumpFormat(path string) (string, error) {
+	if path == "-" {
+		return formatYAML, nil
+	}
+	if isURL(path) {
+		if u, err := url.Parse(path); err == nil {
+			if format, err := fileFormat(u.Path); err == nil {
+				return format, nil
+			}
+		}
+		return formatYAML, nil
+	}
+	return fileFormat(path)
+}
+
+// redact converts v to maps and slices with secret fields redacted. Field
+// names are in lower camel case as in configuration files.
+func redact(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		if redactStruct(v, m) {
+			return polytypeMap(m)
+		}
+		return m
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = redact(v.MapIndex(k))
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, v.Len())
+		for i := range s {
+			s[i] = redact(v.Index(i))
+		}
+		return s
+	default:
+		return v.Interface()
+	}
+}
+
+// redactStruct adds exported fields of struct v to m. Fields of embedded
+// structs are added as if they belong to v. It returns true if v embeds
+// polytype.Type.
+func redactStruct(v reflect.Value, m map[string]interface{}) bool {
+	isPolytype := false
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type == polytypeType {
+			if !v.CanAddr() {
+				c := reflect.New(t).Elem()
+				c.Set(v)
+				v = c
+			}
+			isPolytype = true
+			switch value := redact(reflect.ValueOf(v.Field(i).Addr().Interface().(*polytype.Type).Value())).(type) {
+			case map[string]interface{}:
+				for k, fv := range value {
+					m[k] = fv
+				}
+			case polytypeMap:
+				for k, fv := range value {
+					m[k] = fv
+				}
+			}
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if redactStruct(v.Field(i), m) {
+				isPolytype = true
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		name := field.Tag.Get("json")
+		if i := strings.Index(name, ","); i >= 0 {
+			name = name[:i]
+		}
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = lowerCamel(field.Name)
+		}
+		if field.Tag.Get("secret") == "true" {
+			m[name] = redactedValue
+			continue
+		}
+		m[name] = redact(v.Field(i))
+	}
+	return isPolytype
+}
+
+// addTypes copies type names of polymorphic values in source, which is the
+// configuration file decoded from JSON, to the redacted value v.
+func addTypes(v, source interface{}) {
+	switch v := v.(type) {
+	case polytypeMap:
+		if s, ok := source.(map[string]interface{}); ok {
+			for k, sv := range s {
+				if strings.EqualFold(k, "type") {
+					v["type"] = sv
+				}
+			}
+		}
+		addMapTypes(v, source)
+	case map[string]interface{}:
+		addMapTypes(v, source)
+	case []interface{}:
+		if s, ok := source.([]interface{}); ok && len(s) == len(v) {
+			for i := range v {
+				addTypes(v[i], s[i])
+			}
+		}
+	}
+}
+
+// addMapTypes adds types to values of m from source values of the same keys,
+// which are matched case-insensitively as in decoding.
+func addMapTypes(m map[string]interface{}, source interface{}) {
+	s, ok := source.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for k, v := range m {
+		for sk, sv := range s {
+			if strings.EqualFold(k, sk) {
+				addTypes(v, sv)
+				break
+			}
+		}
+	}
+}
+
+// lowerCamel converts an exported field name to lower camel case, e.g.
+// "TCPNoDelay" to "tcpNoDelay".
+func lowerCamel(s string) string {
+	runes := []rune(s)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
@@ -2,9 +2,12 @@ package core
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"runtime"
+	"time"
 
 	"github.com/goburrow/gol"
 	"github.com/goburrow/health"
@@ -13,6 +16,7 @@ import (
 const (
 	pingUri        = "/ping"
 	runtimeUri     = "/runtime"
+	threadsUri     = "/threads"
 	healthCheckUri = "/healthcheck"
 	tasksUri       = "/tasks"
 
@@ -36,8 +40,18 @@ const (
 	adminLoggerName = "gomelon/admin"
 
 	gcTaskName = "gc"
+
+	// DefaultHealthCheckName is the name of the health check registered in
+	// new admin environments.
+	DefaultHealthCheckName = "liveness"
 )
 
+// JSONEncoder writes JSON encoding of v to w. It is used by admin handlers and
+// can be replaced with a faster implementation than encoding/json.
+type JSONEncoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
 // AdminHandler is an item listed in the admin homepage.
 type AdminHandler interface {
 	Path() string
@@ -48,25 +62,113 @@ type AdminHandler interface {
 type AdminEnvironment struct {
 	ServerHandler ServerHandler
 	HealthChecks  health.Registry
+	// BuildInfo is included in health check results under "build" if it is
+	// set, so no health check can have that name.
+	BuildInfo *BuildInfo
+	// JSONEncoder is used for all JSON responses in admin.
+	JSONEncoder JSONEncoder
+	// HealthCheckTimeout is the maximum duration of each health check run by
+	// healthcheck endpoint. Zero means no timeout.
+	HealthCheckTimeout time.Duration
+	// HealthCheckCacheTTL is the duration results of health checks in
+	// HealthChecks registry are reused by healthcheck endpoint.
+	// Zero disables the cache.
+	HealthCheckCacheTTL time.Duration
+	// TaskTimeout is the maximum running duration of tasks. Zero means no
+	// timeout.
+	TaskTimeout time.Duration
+
+	handlers  []AdminHandler
+	endpoints []adminEndpoint
+	tasks     []Task
+
+	contextHealthChecks map[string]ContextHealthCheck
+	// startTime is when the admin environment is started.
+	startTime time.Time
+}
 
-	handlers []AdminHandler
-	tasks    []Task
+// adminEndpoint is a handler which is not listed in the admin homepage.
+type adminEndpoint struct {
+	method  string
+	pattern string
+	handler http.Handler
 }
 
 func NewAdminEnvironment() *AdminEnvironment {
 	env := &AdminEnvironment{
-		HealthChecks: health.NewRegistry(),
+		HealthChecks: newHealthCheckRegistry(),
+		JSONEncoder:  &stdJSONEncoder{},
 	}
 	// Default handlers
-	env.AddHandler(&pingHandler{}, &runtimeHandler{}, &healthCheckHandler{env.HealthChecks})
+	env.AddHandler(&pingHandler{env}, &runtimeHandler{env}, &threadsHandler{}, &healthCheckHandler{env: env})
 	// Default tasks
 	env.AddTask(&gcTask{})
+	// Default health checks
+	env.HealthChecks.Register(DefaultHealthCheckName, &livenessHealthCheck{})
 	return env
 }
 
-// AddTask adds a new task to admin environment. AddTask is not concurrent-safe.
+// AddTask adds new tasks to admin environment. A task replaces the existing
+// task with the same name. AddTask is not concurrent-safe.
 func (env *AdminEnvironment) AddTask(task ...Task) {
-	env.tasks = append(env.tasks, task...)
+	for _, t := range task {
+		if i := env.taskIndex(t.Name()); i >= 0 {
+			env.tasks[i] = t
+		} else {
+			env.tasks = append(env.tasks, t)
+		}
+	}
+}
+
+// RemoveTask removes the task with the given name. It returns false if there
+// is no such task. RemoveTask is not concurrent-safe.
+func (env *AdminEnvironment) RemoveTask(name string) bool {
+	i := env.taskIndex(name)
+	if i < 0 {
+		return false
+	}
+	env.tasks = append(env.tasks[:i], env.tasks[i+1:]...)
+	return true
+}
+
+// checkTasks returns an error if path of a task has been registered by an
+// admin handler, an endpoint or another task. The error names both of them.
+func (env *AdminEnvironment) checkTasks() error {
+	// registered contains owners of method and path.
+	registered := make(map[string]string)
+	for _, h := range env.handlers {
+		registered["* "+h.Path()] = fmt.Sprintf("admin handler %q", h.Name())
+	}
+	for _, e := range env.endpoints {
+		registered[e.method+" "+e.pattern] = fmt.Sprintf("endpoint %s %s", e.method, e.pattern)
+	}
+	registered["GET "+tasksUri] = fmt.Sprintf("endpoint GET %s", tasksUri)
+	for _, task := range env.tasks {
+		path := taskPath(task)
+		for _, method := range taskMethods(task) {
+			owner, ok := registered["* "+path]
+			if !ok {
+				owner, ok = registered[method+" "+path]
+			}
+			if ok {
+				return fmt.Errorf("core: task %q (%s %s) conflicts with %s", task.Name(), method, path, owner)
+			}
+		}
+		for _, method := range taskMethods(task) {
+			registered[method+" "+path] = fmt.Sprintf("task %q", task.Name())
+		}
+	}
+	return nil
+}
+
+// taskIndex returns index of the task with the given name or -1.
+func (env *AdminEnvironment) taskIndex(name string) int {
+	for i, t := range env.tasks {
+		if t.Name() == name {
+			return i
+		}
+	}
+	return -1
 }
 
 // AddHandler registers a handler entry for admin page.
@@ -74,8 +176,23 @@ func (env *AdminEnvironment) AddHandler(handler ...AdminHandler) {
 	env.handlers = append(env.handlers, handler...)
 }
 
+// AddEndpoint registers the handler for the given method and pattern when the
+// server starts. Unlike AddHandler, the endpoint is not listed in admin page.
+// AddEndpoint is not concurrent-safe.
+func (env *AdminEnvironment) AddEndpoint(method, pattern string, handler http.Handler) {
+	env.endpoints = append(env.endpoints, adminEndpoint{method, pattern, handler})
+}
+
 // onStarting registers all required HTTP handlers
-func (env *AdminEnvironment) onStarting() {
+func (env *AdminEnvironment) onStarting() error {
+	// Nothing is registered if tasks or health checks conflict.
+	if err := env.checkTasks(); err != nil {
+		return err
+	}
+	if err := env.checkHealthChecks(); err != nil {
+		return err
+	}
+	env.startTime = time.Now()
 	env.ServerHandler.Handle("GET", "/", &adminIndex{
 		handlers:    env.handlers,
 		contextPath: env.ServerHandler.PathPrefix(),
@@ -84,13 +201,23 @@ func (env *AdminEnvironment) onStarting() {
 	for _, h := range env.handlers {
 		env.ServerHandler.Handle("*", h.Path(), h)
 	}
+	for _, e := range env.endpoints {
+		env.ServerHandler.Handle(e.method, e.pattern, e.handler)
+	}
 	// Registered tasks
+	env.ServerHandler.Handle("GET", tasksUri, &tasksHandler{env})
 	for _, task := range env.tasks {
-		path := tasksUri + "/" + task.Name()
-		env.ServerHandler.Handle("POST", path, task)
+		var handler http.Handler = &countedTask{task}
+		if timeout := taskTimeout(task, env.TaskTimeout); timeout > 0 {
+			handler = &timeoutTask{handler, timeout}
+		}
+		for _, method := range taskMethods(task) {
+			env.ServerHandler.Handle(method, taskPath(task), handler)
+		}
 	}
 	env.logTasks()
 	env.logHealthChecks()
+	return nil
 }
 
 func (env *AdminEnvironment) onStopped() {
@@ -102,18 +229,33 @@ func (env *AdminEnvironment) logTasks() {
 	if !logger.InfoEnabled() {
 		return
 	}
+	logger.Info("tasks =\n\n%s", env.taskEndpoints())
+}
+
+// taskEndpoints returns all registered tasks with their full paths.
+func (env *AdminEnvironment) taskEndpoints() string {
 	var buf bytes.Buffer
 	for _, task := range env.tasks {
-		fmt.Fprintf(&buf, "    %-7s %s%s/%s (%T)\n", "POST",
-			env.ServerHandler.PathPrefix(), tasksUri, task.Name(), task)
+		for _, method := range taskMethods(task) {
+			fmt.Fprintf(&buf, "    %-7s %s%s (%T)\n", method,
+				env.ServerHandler.PathPrefix(), taskPath(task), task)
+		}
 	}
-	logger.Info("tasks =\n\n%s", buf.String())
+	return buf.String()
 }
 
-// logTasks prints all registered tasks to the log
+// taskPath returns path of the task relative to the admin path prefix.
+func taskPath(task Task) string {
+	return tasksUri + "/" + task.Name()
+}
+
+// logHealthChecks prints all registered health checks to the log
 func (env *AdminEnvironment) logHealthChecks() {
 	logger := gol.GetLogger(adminLoggerName)
 	names := env.HealthChecks.Names()
+	for name := range env.contextHealthChecks {
+		names = append(names, name)
+	}
 	if len(names) <= 0 {
 		logger.Warn(noHealthChecksWarning)
 	}
@@ -131,8 +273,8 @@ func (handler *adminIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var buf bytes.Buffer
 
 	for _, h := range handler.handlers {
-		fmt.Fprintf(&buf, "<li><a href=\"%[1]s%[2]s\">%[3]s</a></li>",
-			handler.contextPath, h.Path(), h.Name())
+		fmt.Fprintf(&buf, "<li><a href=\"%[1]s\">%[2]s</a></li>",
+			ExternalURL(r, handler.contextPath+h.Path()), h.Name())
 	}
 
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
@@ -141,64 +283,10 @@ func (handler *adminIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, adminHTML, buf.String())
 }
 
-// healthCheckHandler is the http handler for /healthcheck page
-type healthCheckHandler struct {
-	registry health.Registry
-}
-
-func (handler *healthCheckHandler) Name() string {
-	return "Healthcheck"
-}
-
-func (handler *healthCheckHandler) Path() string {
-	return healthCheckUri
-}
-
-func (handler *healthCheckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
-
-	results := handler.registry.RunHealthChecks()
-	if len(results) == 0 {
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte("No health checks registered."))
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	if !isAllHealthy(results) {
-		w.WriteHeader(http.StatusInternalServerError)
-	}
-	first := true
-	w.Write([]byte("{"))
-	for name, result := range results {
-		if first {
-			first = false
-		} else {
-			w.Write([]byte(","))
-		}
-		fmt.Fprintf(w, "\n%q: {\"Healthy\": %t", name, result.Healthy())
-		if result.Message() != "" {
-			fmt.Fprintf(w, ", \"Message\": %q", result.Message())
-		}
-		if result.Cause() != nil {
-			fmt.Fprintf(w, ", \"Cause\": %q", result.Cause())
-		}
-		w.Write([]byte("}"))
-	}
-	w.Write([]byte("\n}\n"))
-}
-
-// isAllHealthy checks if all are healthy
-func isAllHealthy(results map[string]health.Result) bool {
-	for _, result := range results {
-		if !result.Healthy() {
-			return false
-		}
-	}
-	return true
-}
-
-// pingHandler handles ping request to admin /ping
+// pingHandler handles ping request to admin /ping. Start time and uptime
+// are responded after "pong" line.
 type pingHandler struct {
+	env *AdminEnvironment
 }
 
 func (handler *pingHandler) Name() string {
@@ -213,10 +301,26 @@ func (handler *pingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte("pong\n"))
+	if startTime := handler.env.startTime; !startTime.IsZero() {
+		fmt.Fprintf(w, "started: %s\nuptime: %v\n", startTime.Format(time.RFC3339), time.Since(startTime))
+	}
 }
 
-// runtimeHandler displays runtime statistics.
+// runtimeHandler displays runtime statistics in plain text, or JSON if it
+// is requested in Accept header.
 type runtimeHandler struct {
+	env *AdminEnvironment
+}
+
+// runtimeStats is the JSON representation of runtime statistics.
+type runtimeStats struct {
+	GOARCH       string
+	GOOS         string
+	Version      string
+	NumCPU       int
+	NumCgoCall   int64
+	NumGoroutine int
+	MemStats     *runtime.MemStats
 }
 
 func (handler *runtimeHandler) Name() string {
@@ -229,6 +333,21 @@ func (handler *runtimeHandler) Path() string {
 
 func (handler *runtimeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	if !acceptsPlainText(r, true) {
+		stats := &runtimeStats{
+			GOARCH:       runtime.GOARCH,
+			GOOS:         runtime.GOOS,
+			Version:      runtime.Version(),
+			NumCPU:       runtime.NumCPU(),
+			NumCgoCall:   runtime.NumCgoCall(),
+			NumGoroutine: runtime.NumGoroutine(),
+			MemStats:     &runtime.MemStats{},
+		}
+		runtime.ReadMemStats(stats.MemStats)
+		w.Header().Set("Content-Type", "application/json")
+		handler.env.JSONEncoder.Encode(w, stats)
+		return
+	}
 	w.Header().Set("Content-Type", "text/plain")
 
 	fmt.Fprintf(w, "GOARCH: %s\nGOOS: %s\nVersion: %s\nNumCPU: %d\nNumCgoCall: %d\nNumGoroutine: %d\n",
@@ -260,7 +379,70 @@ func (*gcTask) Name() string {
 }
 
 func (*gcTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var before, after runtime.MemStats
+
+	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte("Running GC...\n"))
+	runtime.ReadMemStats(&before)
 	runtime.GC()
+	runtime.ReadMemStats(&after)
+	fmt.Fprintf(w, "HeapAlloc before: %d\nHeapAlloc after: %d\nFreed: %d\n",
+		before.HeapAlloc, after.HeapAlloc, int64(before.HeapAlloc)-int64(after.HeapAlloc))
 	w.Write([]byte("Done!\n"))
 }
+
+// stdJSONEncoder is the default JSONEncoder using encoding/json package.
+type stdJSONEncoder struct {
+}
+
+func (*stdJSONEncoder) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// threadsHandler displays stack traces of all goroutines.
+type threadsHandler struct {
+}
+
+func (handler *threadsHandler) Name() string {
+	return "Threads"
+}
+
+func (handler *threadsHandler) Path() string {
+	return threadsUri
+}
+
+// ServeHTTP writes goroutine dump. Only goroutines of which the state contains
+// state query parameter are included if it is given.
+func (handler *threadsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	w.Header().Set("Content-Type", "text/plain")
+
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		w.Write(buf)
+		return
+	}
+	// Goroutines are separated by an empty line and start with a header
+	// like "goroutine 1 [chan receive]:".
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		header := g
+		if i := bytes.IndexByte(g, '\n'); i >= 0 {
+			header = g[:i]
+		}
+		start := bytes.IndexByte(header, '[')
+		end := bytes.LastIndex(header, []byte("]"))
+		if start >= 0 && end > start && bytes.Contains(header[start+1:end], []byte(state)) {
+			w.Write(g)
+			w.Write([]byte("\n\n"))
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServerHandler records registered handlers.
+type testServerHandler struct {
+	pathPrefix string
+	handlers   map[string]http.Handler
+}
+
+func newTestServerHandler(pathPrefix string) *testServerHandler {
+	return &testServerHandler{
+		pathPrefix: pathPrefix,
+		handlers:   make(map[string]http.Handler),
+	}
+}
+
+func (h *testServerHandler) Handle(method, pattern string, handler interface{}) {
+	h.handlers[method+" "+pattern] = handler.(http.Handler)
+}
+
+func (h *testServerHandler) PathPrefix() string {
+	return h.pathPrefix
+}
+
+// ServeHTTP dispatches request with path prefix like a real ServerHandler.
+func (h *testServerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, h.pathPrefix) {
+		path := strings.TrimPrefix(r.URL.Path, h.pathPrefix)
+		for _, method := range []string{r.Method, "*"} {
+			if handler, ok := h.handlers[method+" "+path]; ok {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
+
+type testAdminHandler struct {
+	path string
+}
+
+func (h *testAdminHandler) Name() string {
+	return "Test"
+}
+
+func (h *testAdminHandler) Path() string {
+	return h.path
+}
+
+func (h *testAdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+}
+
+func TestAdminIndex(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddHandler(&testAdminHandler{"/test"})
+	serverHandler := newTestServerHandler("/admin")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	index, ok := serverHandler.handlers["GET /"]
+	if !ok {
+		t.Fatalf("admin index is not registered: %v", serverHandler.handlers)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/admin/", nil)
+	index.ServeHTTP(w, r)
+
+	body := w.Body.String()
+	for _, path := range []string{pingUri, runtimeUri, healthCheckUri, "/test"} {
+		if !strings.Contains(body, `href="/admin`+path+`"`) {
+			t.Fatalf("missing link %s: %s", path, body)
+		}
+	}
+	// Metrics handler is only added by metrics factory.
+	if strings.Contains(body, "/metrics") {
+		t.Fatalf("unexpected link /metrics: %s", body)
+	}
+	// Scheme and host restored from trusted proxies.
+	w = httptest.NewRecorder()
+	r.URL.Scheme = "https"
+	r.URL.Host = "example.com"
+	index.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `href="https://example.com/admin/ping"`) {
+		t.Fatalf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestAdminTaskPath(t *testing.T) {
+	env := NewAdminEnvironment()
+	serverHandler := newTestServerHandler("/admin")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	endpoints := env.taskEndpoints()
+	expected := "POST    /admin/tasks/gc (*core.gcTask)"
+	if !strings.Contains(endpoints, expected) {
+		t.Fatalf("unexpected endpoints %q, expected %q", endpoints, expected)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/admin/tasks/gc", nil)
+	serverHandler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+type testMethodTask struct {
+}
+
+func (*testMethodTask) Name() string {
+	return "test"
+}
+
+func (*testMethodTask) Methods() []string {
+	return []string{"GET", "PUT"}
+}
+
+func (*testMethodTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+}
+
+func TestAdminTaskMethods(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddTask(&testMethodTask{})
+	serverHandler := newTestServerHandler("")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	for _, pattern := range []string{"POST /tasks/gc", "GET /tasks/test", "PUT /tasks/test"} {
+		if _, ok := serverHandler.handlers[pattern]; !ok {
+			t.Fatalf("%s is not registered: %v", pattern, serverHandler.handlers)
+		}
+	}
+	if _, ok := serverHandler.handlers["POST /tasks/test"]; ok {
+		t.Fatalf("unexpected POST /tasks/test: %v", serverHandler.handlers)
+	}
+}
+
+func TestAdminThreads(t *testing.T) {
+	handler := &threadsHandler{}
+	block := make(chan struct{})
+	defer close(block)
+	go func() {
+		<-block
+	}()
+	// Wait until the goroutine is blocked.
+	time.Sleep(10 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/threads", nil)
+	handler.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "[running]") || !strings.Contains(w.Body.String(), "[chan receive]") {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/threads?state=chan+receive", nil)
+	handler.ServeHTTP(w, r)
+	if strings.Contains(w.Body.String(), "[running]") || !strings.Contains(w.Body.String(), "[chan receive]") {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+}
+
+type slowTask struct {
+	cancelled chan struct{}
+}
+
+func (*slowTask) Name() string {
+	return "slow"
+}
+
+func (t *slowTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-r.Context().Done():
+		close(t.cancelled)
+	case <-time.After(time.Second):
+		w.Write([]byte("done"))
+	}
+}
+
+func TestAdminTaskTimeout(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.TaskTimeout = 10 * time.Millisecond
+	handler := newTestServerHandler("")
+	env.ServerHandler = handler
+	task := &slowTask{make(chan struct{})}
+	env.AddTask(task)
+	env.onStarting()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/slow", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	select {
+	case <-task.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("task context is not cancelled")
+	}
+	// Completed tasks
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/tasks/gc", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestAdminTaskTimeoutPanic(t *testing.T) {
+	task := &timeoutTask{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("task failed")
+	}), time.Second}
+	defer func() {
+		if p := recover(); p != "task failed" {
+			t.Fatalf("unexpected panic %v", p)
+		}
+	}()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/panic", nil)
+	task.ServeHTTP(w, r)
+	t.Fatal("panic is not propagated")
+}
+
+func TestAdminGCTask(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/gc", nil)
+	(&gcTask{}).ServeHTTP(w, r)
+
+	body := w.Body.String()
+	for _, s := range []string{"HeapAlloc before: ", "HeapAlloc after: ", "Freed: ", "Done!"} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("missing %s: %s", s, body)
+		}
+	}
+	if w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
+
+func TestAdminFuncTask(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddTask(NewTask("ok", func(w io.Writer, r *http.Request) error {
+		w.Write([]byte("done"))
+		return nil
+	}))
+	env.AddTask(NewTask("fail", func(w io.Writer, r *http.Request) error {
+		w.Write([]byte("partial output"))
+		return errors.New("something went wrong")
+	}))
+	serverHandler := newTestServerHandler("")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/ok", nil)
+	serverHandler.handlers["POST /tasks/ok"].ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/tasks/fail", nil)
+	serverHandler.handlers["POST /tasks/fail"].ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "something went wrong\n" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	// Handler tasks
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/tasks/gc", nil)
+	serverHandler.handlers["POST /tasks/gc"].ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Done!") {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminPing(t *testing.T) {
+	env := NewAdminEnvironment()
+	handler := &pingHandler{env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/ping", nil)
+	handler.ServeHTTP(w, r)
+	if w.Body.String() != "pong\n" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	env.ServerHandler = newTestServerHandler("")
+	env.onStarting()
+	uptime := func() time.Duration {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		lines := strings.Split(w.Body.String(), "\n")
+		if len(lines) != 4 || lines[0] != "pong" || !strings.HasPrefix(lines[1], "started: ") {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+		d, err := time.ParseDuration(strings.TrimPrefix(lines[2], "uptime: "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	first := uptime()
+	time.Sleep(time.Millisecond)
+	if second := uptime(); second <= first {
+		t.Fatalf("uptime does not increase: %v, %v", first, second)
+	}
+}
+
+func TestAdminRuntime(t *testing.T) {
+	env := NewAdminEnvironment()
+	handler := &runtimeHandler{env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/runtime", nil)
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != "text/plain" || !strings.HasPrefix(w.Body.String(), "GOARCH: ") {
+		t.Fatalf("unexpected response %v %v", w.Header(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("Accept", "application/json")
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	var stats runtimeStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Version != runtime.Version() || stats.NumCPU != runtime.NumCPU() ||
+		stats.NumGoroutine <= 0 || stats.MemStats == nil || stats.MemStats.HeapAlloc == 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("Accept", "text/plain")
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
+
+func TestAdminRemoveTask(t *testing.T) {
+	env := NewAdminEnvironment()
+	if !env.RemoveTask(gcTaskName) {
+		t.Fatal("gc task is not removed")
+	}
+	if env.RemoveTask(gcTaskName) {
+		t.Fatal("unexpected task removed")
+	}
+	serverHandler := newTestServerHandler("/admin")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/admin/tasks/gc", nil)
+	serverHandler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestAdminReplaceTask(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddTask(&testMethodTask{})
+	env.AddTask(NewTask(gcTaskName, func(w io.Writer, r *http.Request) error {
+		_, err := io.WriteString(w, "replaced")
+		return err
+	}))
+	if len(env.tasks) != 2 || env.tasks[0].Name() != gcTaskName || env.tasks[1].Name() != "test" {
+		t.Fatalf("unexpected tasks %v", env.tasks)
+	}
+	serverHandler := newTestServerHandler("/admin")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/admin/tasks/gc", nil)
+	serverHandler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "replaced" {
+		t.Fatalf("unexpected response %v %q", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminDuplicateTask(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddEndpoint("POST", "/tasks/gc", http.NotFoundHandler())
+	env.ServerHandler = newTestServerHandler("/admin")
+	err := env.onStarting()
+	if err == nil || err.Error() != `core: task "gc" (POST /tasks/gc) conflicts with endpoint POST /tasks/gc` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	env = NewAdminEnvironment()
+	env.AddHandler(&testAdminHandler{"/tasks/test"})
+	env.AddTask(&testMethodTask{})
+	env.ServerHandler = newTestServerHandler("/admin")
+	err = env.onStarting()
+	if err == nil || err.Error() != `core: task "test" (GET /tasks/test) conflicts with admin handler "Test"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -9,3 +9,23 @@ type Application interface {
 	// Run runs application with the given configuration and environment.
 	Run(interface{}, *Environment) error
 }
+
+// BuildInfo contains build metadata of the application, e.g. version and
+// git commit which are usually provided by the linker.
+type BuildInfo struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
+// String returns version followed by commit and date if they are available.
+func (info *BuildInfo) String() string {
+	s := info.Version
+	if info.Commit != "" {
+		s += " " + info.Commit
+	}
+	if info.Date != "" {
+		s += " " + info.Date
+	}
+	return s
+}
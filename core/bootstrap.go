@@ -1,9 +1,19 @@
 package core
 
+import (
+	"fmt"
+	"net/http"
+)
+
 // Bootstrap contains everything required to bootstrap a command
 type Bootstrap struct {
 	Application Application
 	Arguments   []string
+	// BuildInfo is optional build metadata of the application.
+	BuildInfo *BuildInfo
+	// HandlerWrapper optionally wraps the application handler when the
+	// server is built. See ServerEnvironment.HandlerWrapper.
+	HandlerWrapper func(http.Handler) http.Handler
 
 	ConfigurationFactory ConfigurationFactory
 	ValidatorFactory     ValidatorFactory
@@ -41,12 +51,39 @@ func (bootstrap *Bootstrap) AddCommand(command Command) {
 	bootstrap.commands = append(bootstrap.commands, command)
 }
 
-// run runs all registered bundles
+// Run runs all registered bundles in the order they are added. It stops at
+// the first bundle which returns an error. ConfiguredBundle receives its own
+// section of the configuration.
 func (bootstrap *Bootstrap) Run(configuration interface{}, environment *Environment) error {
 	for _, bundle := range bootstrap.bundles {
-		if err := bundle.Run(configuration, environment); err != nil {
+		bundleConfiguration := configuration
+		if b, ok := bundle.(ConfiguredBundle); ok {
+			var err error
+			if bundleConfiguration, err = bootstrap.bundleConfiguration(b); err != nil {
+				return err
+			}
+		}
+		if err := bundle.Run(bundleConfiguration, environment); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// bundleConfiguration decodes and validates configuration of the bundle.
+func (bootstrap *Bootstrap) bundleConfiguration(bundle ConfiguredBundle) (interface{}, error) {
+	decoder, ok := bootstrap.ConfigurationFactory.(ConfigurationSectionDecoder)
+	if !ok {
+		return nil, fmt.Errorf("core: configuration factory %T does not support configured bundles", bootstrap.ConfigurationFactory)
+	}
+	configuration := bundle.Configuration()
+	if err := decoder.DecodeSection(bundle.ConfigurationKey(), configuration); err != nil {
+		return nil, err
+	}
+	if bootstrap.ValidatorFactory != nil {
+		if err := bootstrap.ValidatorFactory.Validator().Validate(configuration); err != nil {
+			return nil, err
+		}
+	}
+	return configuration, nil
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+type testBundle struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (b *testBundle) Initialize(*Bootstrap) {
+	*b.calls = append(*b.calls, b.name+".Initialize")
+}
+
+func (b *testBundle) Run(interface{}, *Environment) error {
+	*b.calls = append(*b.calls, b.name+".Run")
+	return b.err
+}
+
+func TestBootstrapBundles(t *testing.T) {
+	var calls []string
+	bootstrap := NewBootstrap(nil)
+	bootstrap.AddBundle(&testBundle{name: "a", calls: &calls})
+	bootstrap.AddBundle(&testBundle{name: "b", calls: &calls})
+	if len(bootstrap.Bundles()) != 2 {
+		t.Fatalf("unexpected bundles %v", bootstrap.Bundles())
+	}
+	if err := bootstrap.Run(nil, NewEnvironment()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a.Initialize", "b.Initialize", "a.Run", "b.Run"}
+	if len(calls) != len(expected) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Fatalf("unexpected calls %v", calls)
+		}
+	}
+}
+
+func TestBootstrapBundleError(t *testing.T) {
+	var calls []string
+	bootstrap := NewBootstrap(nil)
+	bootstrap.AddBundle(&testBundle{name: "a", calls: &calls, err: errors.New("a")})
+	bootstrap.AddBundle(&testBundle{name: "b", calls: &calls})
+	if err := bootstrap.Run(nil, NewEnvironment()); err == nil || err.Error() != "a" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if calls[len(calls)-1] != "a.Run" {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+}
+
+type testConfiguredBundle struct {
+	testBundle
+}
+
+func (b *testConfiguredBundle) ConfigurationKey() string {
+	return "test"
+}
+
+func (b *testConfiguredBundle) Configuration() interface{} {
+	return &struct{}{}
+}
+
+type testConfigurationFactory struct{}
+
+func (*testConfigurationFactory) Build(*Bootstrap) (interface{}, error) {
+	return nil, nil
+}
+
+func TestBootstrapConfiguredBundleUnsupported(t *testing.T) {
+	var calls []string
+	bootstrap := NewBootstrap(nil)
+	bootstrap.ConfigurationFactory = &testConfigurationFactory{}
+	bootstrap.AddBundle(&testConfiguredBundle{testBundle{name: "a", calls: &calls}})
+	if err := bootstrap.Run(nil, NewEnvironment()); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -7,3 +7,14 @@ type Bundle interface {
 	// Run runs bundle with the given configuration and environment.
 	Run(interface{}, *Environment) error
 }
+
+// ConfiguredBundle is a bundle which has its own section in the configuration.
+// The section is decoded to the value returned by Configuration and passed to
+// Run instead of the application configuration.
+type ConfiguredBundle interface {
+	Bundle
+	// ConfigurationKey returns the top level key of the bundle section.
+	ConfigurationKey() string
+	// Configuration returns a pointer which the section is decoded to.
+	Configuration() interface{}
+}
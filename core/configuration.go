@@ -10,3 +10,18 @@ type Configuration interface {
 type ConfigurationFactory interface {
 	Build(bootstrap *Bootstrap) (interface{}, error)
 }
+
+// ConfigurationSectionDecoder is implemented by ConfigurationFactory which
+// supports ConfiguredBundle.
+type ConfigurationSectionDecoder interface {
+	// DecodeSection decodes the section of key in the configuration to output.
+	DecodeSection(key string, output interface{}) error
+}
+
+// ConfigurationDumper is implemented by ConfigurationFactory which can encode
+// the configuration it has built.
+type ConfigurationDumper interface {
+	// Dump encodes the configuration in the format of the file at path with
+	// secret values redacted.
+	Dump(path string) ([]byte, error)
+}
@@ -1,5 +1,11 @@
 package core
 
+import (
+	"errors"
+
+	"github.com/goburrow/health"
+)
+
 // Environment also implements Managed interface so that it can be initilizen
 // when server starts.
 type Environment struct {
@@ -14,6 +20,8 @@ type Environment struct {
 	Admin *AdminEnvironment
 	// Validator validates communication data structures.
 	Validator Validator
+	// Metrics records metrics of the application.
+	Metrics *MetricRegistry
 
 	eventListeners []eventListener
 }
@@ -24,6 +32,7 @@ func NewEnvironment() *Environment {
 		Server:    NewServerEnvironment(),
 		Lifecycle: NewLifecycleEnvironment(),
 		Admin:     NewAdminEnvironment(),
+		Metrics:   NewMetricRegistry(),
 	}
 	env.eventListeners = []eventListener{
 		env.Server,
@@ -33,16 +42,61 @@ func NewEnvironment() *Environment {
 	return env
 }
 
+// AddHealthCheck registers the health check to the admin environment. Checks
+// which implement ContextHealthCheck are given the context of the request.
+func (env *Environment) AddHealthCheck(name string, check health.HealthCheck) {
+	if c, ok := check.(ContextHealthCheck); ok {
+		env.Admin.RegisterHealthCheck(name, c)
+	} else {
+		env.Admin.HealthChecks.Register(name, check)
+	}
+}
+
+// AddFilter adds the filter to the application handler. It runs after
+// filters configured in the server factory and before the request handler.
+// The type of the filter depends on the ServerHandler, i.e. filter.Filter for
+// server.Handler. Filters must be added before the server starts.
+func (env *Environment) AddFilter(filter interface{}) error {
+	handler, err := env.filterHandler()
+	if err != nil {
+		return err
+	}
+	return handler.AddFilter(filter)
+}
+
+// AddFilterBefore inserts the filter to the application handler before the
+// filter with the given name.
+func (env *Environment) AddFilterBefore(filter interface{}, name string) error {
+	handler, err := env.filterHandler()
+	if err != nil {
+		return err
+	}
+	return handler.InsertFilter(filter, name)
+}
+
+func (env *Environment) filterHandler() (FilterHandler, error) {
+	handler, ok := env.Server.ServerHandler.(FilterHandler)
+	if !ok {
+		return nil, errors.New("core: server handler does not support filters")
+	}
+	return handler, nil
+}
+
 // eventListener is used internally to intialize/finalize environment.
 type eventListener interface {
-	onStarting()
+	onStarting() error
 	onStopped()
 }
 
-func (env *Environment) SetStarting() {
+// SetStarting prepares the environment before the server starts. SetStopped
+// must still be called if it returns an error.
+func (env *Environment) SetStarting() error {
 	for i, _ := range env.eventListeners {
-		env.eventListeners[i].onStarting()
+		if err := env.eventListeners[i].onStarting(); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (env *Environment) SetStopped() {
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is an error with HTTP status which is rendered by WriteError.
+type HTTPError struct {
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Message is sent to the client.
+	Message string `json:"message"`
+	// Code is an optional application-specific error code.
+	Code string `json:"code,omitempty"`
+}
+
+// NewHTTPError allocates and returns a new HTTPError.
+func NewHTTPError(status int, message string) *HTTPError {
+	return &HTTPError{
+		Status:  status,
+		Message: message,
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+// WriteError writes the error to the response. It is written as JSON if the
+// request accepts application/json, otherwise as plain text. Errors other
+// than HTTPError are sent as 500 Internal Server Error without details.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e, ok := err.(*HTTPError)
+	if !ok {
+		e = NewHTTPError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	} else if e.Status == 0 {
+		e = &HTTPError{http.StatusInternalServerError, e.Message, e.Code}
+	}
+	if r == nil || !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		http.Error(w, e.Message, e.Status)
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		http.Error(w, e.Message, e.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	w.Write(append(b, '\n'))
+}
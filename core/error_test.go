@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err         error
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{&HTTPError{http.StatusConflict, "user exists", "USER_EXISTS"}, "application/json",
+			http.StatusConflict, "application/json; charset=utf-8",
+			`{"status":409,"message":"user exists","code":"USER_EXISTS"}` + "\n"},
+		{NewHTTPError(http.StatusNotFound, "no user"), "application/json",
+			http.StatusNotFound, "application/json; charset=utf-8",
+			`{"status":404,"message":"no user"}` + "\n"},
+		{NewHTTPError(http.StatusNotFound, "no user"), "",
+			http.StatusNotFound, "text/plain; charset=utf-8", "no user\n"},
+		{errors.New("connection refused"), "application/json",
+			http.StatusInternalServerError, "application/json; charset=utf-8",
+			`{"status":500,"message":"Internal Server Error"}` + "\n"},
+		{errors.New("connection refused"), "text/html",
+			http.StatusInternalServerError, "text/plain; charset=utf-8", "Internal Server Error\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		WriteError(w, r, test.err)
+		if w.Code != test.code || w.Header().Get("Content-Type") != test.contentType ||
+			w.Body.String() != test.body {
+			t.Fatalf("unexpected response %v %v %q for %+v", w.Code, w.Header(), w.Body.String(), test)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/health"
+	"golang.org/x/net/context"
+)
+
+// ContextHealthCheck is a health check which is given the context of the admin
+// request. The context is cancelled when the client aborts the request, so
+// downstream calls made by the check can be cancelled as well.
+type ContextHealthCheck interface {
+	CheckContext(context.Context) health.Result
+}
+
+// livenessHealthCheck is always healthy as it is reached only when the
+// application is serving admin requests.
+type livenessHealthCheck struct{}
+
+func (*livenessHealthCheck) Check() health.Result {
+	return health.Healthy
+}
+
+// RegisterHealthCheck registers a context-aware health check. Unlike health
+// checks in HealthChecks registry, results of these checks are not shared
+// between concurrent requests. RegisterHealthCheck is not concurrent-safe.
+func (env *AdminEnvironment) RegisterHealthCheck(name string, check ContextHealthCheck) {
+	if env.contextHealthChecks == nil {
+		env.contextHealthChecks = make(map[string]ContextHealthCheck)
+	}
+	env.contextHealthChecks[name] = check
+}
+
+// buildInfoHealthCheckKey is the key of build info in health check results,
+// which can not be used as a health check name when build info is set.
+const buildInfoHealthCheckKey = "build"
+
+// checkHealthChecks returns an error if a health check name conflicts with
+// build info in health check results.
+func (env *AdminEnvironment) checkHealthChecks() error {
+	if env.BuildInfo == nil {
+		return nil
+	}
+	_, registered := env.contextHealthChecks[buildInfoHealthCheckKey]
+	for _, name := range env.HealthChecks.Names() {
+		registered = registered || name == buildInfoHealthCheckKey
+	}
+	if registered {
+		return fmt.Errorf("core: health check name %q is reserved for build info", buildInfoHealthCheckKey)
+	}
+	return nil
+}
+
+// healthCheckHandler is the http handler for /healthcheck page
+type healthCheckHandler struct {
+	env   *AdminEnvironment
+	group healthCheckGroup
+	cache healthCheckCache
+}
+
+func (handler *healthCheckHandler) Name() string {
+	return "Healthcheck"
+}
+
+func (handler *healthCheckHandler) Path() string {
+	return healthCheckUri
+}
+
+func (handler *healthCheckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	buildInfo := handler.env.BuildInfo
+	if buildInfo != nil {
+		w.Header().Set("X-Build-Info", buildInfo.String())
+	}
+
+	results, missing := handler.runHealthChecks(r, r.URL.Query()["name"])
+	if missing != "" {
+		http.Error(w, fmt.Sprintf("Health check %s not found.", missing), http.StatusNotFound)
+		return
+	}
+	if len(results) == 0 {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte("No health checks registered."))
+		return
+	}
+	if age := cacheAge(results); age > 0 {
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	}
+	if acceptsPlainText(r, false) {
+		handler.writeText(w, results)
+		return
+	}
+	output := make(map[string]interface{}, len(results)+1)
+	if buildInfo != nil {
+		output[buildInfoHealthCheckKey] = buildInfo
+	}
+	now := time.Now()
+	for name, result := range results {
+		r := &healthCheckResult{
+			Healthy: result.Healthy(),
+			Message: result.Message(),
+		}
+		if result.Cause() != nil {
+			r.Cause = result.Cause().Error()
+		}
+		if cached, ok := result.(*cachedHealthCheckResult); ok {
+			if age := now.Sub(cached.time); age >= time.Second {
+				r.Age = age.String()
+			}
+		}
+		output[name] = r
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !isAllHealthy(results) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := handler.env.JSONEncoder.Encode(w, output); err != nil {
+		// Response is partially written, most likely the client has gone.
+		gol.GetLogger(adminLoggerName).Debug("healthcheck: error writing response: %v", err)
+	}
+}
+
+// writeText writes results in plain text, one health check per line.
+func (handler *healthCheckHandler) writeText(w http.ResponseWriter, results map[string]health.Result) {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		result := results[name]
+		if result.Healthy() {
+			fmt.Fprintf(&buf, "* %s: OK", name)
+		} else {
+			fmt.Fprintf(&buf, "! %s: ERROR", name)
+		}
+		if result.Message() != "" {
+			fmt.Fprintf(&buf, " %s", result.Message())
+		}
+		if result.Cause() != nil {
+			fmt.Fprintf(&buf, " (%v)", result.Cause())
+		}
+		buf.WriteByte('\n')
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !isAllHealthy(results) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		gol.GetLogger(adminLoggerName).Debug("healthcheck: error writing response: %v", err)
+	}
+}
+
+// acceptsPlainText returns true if the client prefers text/plain to JSON.
+// It returns fallback if neither of them is in Accept header.
+func acceptsPlainText(r *http.Request, fallback bool) bool {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "application/json") {
+		return false
+	}
+	if strings.Contains(accept, "text/plain") {
+		return true
+	}
+	return fallback
+}
+
+// runHealthChecks runs health checks with the given names or all of them if
+// names is empty. It returns the first name which is not registered.
+func (handler *healthCheckHandler) runHealthChecks(r *http.Request,
+	names []string) (map[string]health.Result, string) {
+	if len(names) == 0 {
+		// Concurrent requests share results of the same run.
+		results := handler.group.do("", func() map[string]health.Result {
+			return handler.runRegistryHealthChecks(nil)
+		})
+		if len(handler.env.contextHealthChecks) > 0 {
+			results = handler.runContextHealthChecks(r, results, nil)
+		}
+		return results, ""
+	}
+	registered := make(map[string]bool)
+	for _, name := range handler.env.HealthChecks.Names() {
+		registered[name] = true
+	}
+	var registryNames, contextNames []string
+	for _, name := range names {
+		if _, ok := handler.env.contextHealthChecks[name]; ok {
+			contextNames = append(contextNames, name)
+		} else if registered[name] {
+			registryNames = append(registryNames, name)
+		} else {
+			return nil, name
+		}
+	}
+	var results map[string]health.Result
+	if len(registryNames) > 0 {
+		sort.Strings(registryNames)
+		results = handler.group.do(strings.Join(registryNames, ","), func() map[string]health.Result {
+			return handler.runRegistryHealthChecks(registryNames)
+		})
+	}
+	if len(contextNames) > 0 {
+		results = handler.runContextHealthChecks(r, results, contextNames)
+	}
+	return results, ""
+}
+
+// runRegistryHealthChecks runs health checks in HealthChecks registry with
+// the given names or all of them if names is nil. Results within the cache TTL
+// are reused.
+func (handler *healthCheckHandler) runRegistryHealthChecks(names []string) map[string]health.Result {
+	ttl := handler.env.HealthCheckCacheTTL
+	if ttl <= 0 {
+		return handler.runRegistry(names)
+	}
+	if names == nil {
+		names = handler.env.HealthChecks.Names()
+	}
+	now := time.Now()
+	results := make(map[string]health.Result, len(names))
+	var stale []string
+	for _, name := range names {
+		if result, ok := handler.cache.get(name, now.Add(-ttl)); ok {
+			results[name] = result
+		} else {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		for name, result := range handler.runRegistry(stale) {
+			results[name] = handler.cache.put(name, result, now)
+		}
+	}
+	return results
+}
+
+// runRegistry runs health checks in HealthChecks registry with the given
+// names or all of them if names is nil.
+func (handler *healthCheckHandler) runRegistry(names []string) map[string]health.Result {
+	registry, ok := handler.env.HealthChecks.(*healthCheckRegistry)
+	if !ok {
+		// Other registries can only run all health checks without timeout.
+		results := handler.env.HealthChecks.RunHealthChecks()
+		if names == nil {
+			return results
+		}
+		selected := make(map[string]health.Result, len(names))
+		for _, name := range names {
+			selected[name] = results[name]
+		}
+		return selected
+	}
+	if names == nil {
+		names = registry.Names()
+	}
+	return registry.run(names, handler.env.HealthCheckTimeout)
+}
+
+// runContextHealthChecks returns results of context-aware health checks
+// combined with the given results. All checks are run if names is nil.
+func (handler *healthCheckHandler) runContextHealthChecks(r *http.Request,
+	results map[string]health.Result, names []string) map[string]health.Result {
+	// Checks are cancelled when the client closes the connection.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if names == nil {
+		for name := range handler.env.contextHealthChecks {
+			names = append(names, name)
+		}
+	}
+	// results is shared so a copy is needed.
+	combined := make(map[string]health.Result, len(results)+len(names))
+	for name, result := range results {
+		combined[name] = result
+	}
+	timeout := handler.env.HealthCheckTimeout
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+	checks := make(map[string]func() health.Result, len(names))
+	for _, name := range names {
+		check := handler.env.contextHealthChecks[name]
+		checks[name] = func() health.Result {
+			return check.CheckContext(ctx)
+		}
+	}
+	// Runs are not shared as checks are bound to the context of this request.
+	for name, result := range runHealthChecks(checks, timeout, nil) {
+		combined[name] = result
+	}
+	return combined
+}
+
+// runHealthChecks runs the given health checks. If timeout is set, checks
+// are run concurrently and those not completed within the timeout are
+// reported as unhealthy. Unless runs is nil, a check which is still running
+// from a previous call is not started again, its result is waited for instead.
+func runHealthChecks(checks map[string]func() health.Result, timeout time.Duration,
+	runs *healthCheckRuns) map[string]health.Result {
+	AddInternalMetric("healthchecks.runs", uint64(len(checks)))
+	results := make(map[string]health.Result, len(checks))
+	if timeout <= 0 {
+		for name, check := range checks {
+			results[name] = check()
+		}
+		return results
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for name, check := range checks {
+		go func(name string, check func() health.Result) {
+			defer wg.Done()
+			result := runHealthCheckTimeout(runs.start(name, check), timeout)
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// runHealthCheckTimeout returns result of the run or an unhealthy result if
+// it does not complete within the timeout. The check is left running in
+// background when timed out.
+func runHealthCheckTimeout(run *healthCheckRun, timeout time.Duration) health.Result {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-run.done:
+		return run.result
+	case <-timer.C:
+		return health.ResultUnhealthy("timed out", nil)
+	}
+}
+
+// healthCheckRun is an in-progress or completed run of a health check.
+type healthCheckRun struct {
+	done   chan struct{}
+	result health.Result
+}
+
+// healthCheckRuns keeps track of health checks running in background so
+// that a check which does not return is not run again in a new goroutine
+// on every request.
+type healthCheckRuns struct {
+	mu   sync.Mutex
+	runs map[string]*healthCheckRun
+}
+
+// start runs the check in background unless it is still running. Runs are
+// not shared if g is nil.
+func (g *healthCheckRuns) start(name string, check func() health.Result) *healthCheckRun {
+	if g == nil {
+		return startHealthCheckRun(check, func() {})
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if run, ok := g.runs[name]; ok {
+		return run
+	}
+	if g.runs == nil {
+		g.runs = make(map[string]*healthCheckRun)
+	}
+	run := startHealthCheckRun(check, func() {
+		g.mu.Lock()
+		delete(g.runs, name)
+		g.mu.Unlock()
+	})
+	g.runs[name] = run
+	return run
+}
+
+// startHealthCheckRun runs the check in background. completed is called
+// before the run is marked as done.
+func startHealthCheckRun(check func() health.Result, completed func()) *healthCheckRun {
+	run := &healthCheckRun{done: make(chan struct{})}
+	go func() {
+		defer func() {
+			// A panicking check must not crash the process nor block
+			// following runs.
+			if p := recover(); p != nil {
+				run.result = health.ResultUnhealthy("panic", fmt.Errorf("%v", p))
+			}
+			completed()
+			close(run.done)
+		}()
+		run.result = check()
+	}()
+	return run
+}
+
+// healthCheckRegistry is the default health check registry of admin
+// environment. Unlike other registries, it can run only selected checks.
+type healthCheckRegistry struct {
+	mu     sync.RWMutex
+	checks map[string]health.HealthCheck
+	runs   healthCheckRuns
+}
+
+var _ health.Registry = (*healthCheckRegistry)(nil)
+
+func newHealthCheckRegistry() *healthCheckRegistry {
+	return &healthCheckRegistry{
+		checks: make(map[string]health.HealthCheck),
+	}
+}
+
+func (r *healthCheckRegistry) Register(name string, check health.HealthCheck) {
+	r.mu.Lock()
+	r.checks[name] = check
+	r.mu.Unlock()
+}
+
+func (r *healthCheckRegistry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.checks, name)
+	r.mu.Unlock()
+}
+
+func (r *healthCheckRegistry) Names() []string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+func (r *healthCheckRegistry) RunHealthChecks() map[string]health.Result {
+	return r.run(r.Names(), 0)
+}
+
+// run runs health checks with the given names which are registered.
+// No timeout if timeout is zero.
+func (r *healthCheckRegistry) run(names []string, timeout time.Duration) map[string]health.Result {
+	r.mu.RLock()
+	checks := make(map[string]func() health.Result, len(names))
+	for _, name := range names {
+		if check, ok := r.checks[name]; ok {
+			checks[name] = check.Check
+		}
+	}
+	r.mu.RUnlock()
+	return runHealthChecks(checks, timeout, &r.runs)
+}
+
+// healthCheckResult is the JSON representation of health.Result.
+type healthCheckResult struct {
+	Healthy bool
+	Message string `json:",omitempty"`
+	Cause   string `json:",omitempty"`
+	// Age is how long ago a cached result was run.
+	Age string `json:",omitempty"`
+}
+
+// isAllHealthy checks if all are healthy
+func isAllHealthy(results map[string]health.Result) bool {
+	for _, result := range results {
+		if !result.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedHealthCheckResult is a health check result with the time it was run.
+type cachedHealthCheckResult struct {
+	health.Result
+	time time.Time
+}
+
+// healthCheckCache stores the latest result of each health check.
+type healthCheckCache struct {
+	mu      sync.Mutex
+	results map[string]*cachedHealthCheckResult
+}
+
+// get returns the cached result of the health check if it was run after
+// the given time.
+func (c *healthCheckCache) get(name string, after time.Time) (*cachedHealthCheckResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[name]
+	if !ok || result.time.Before(after) {
+		return nil, false
+	}
+	return result, true
+}
+
+func (c *healthCheckCache) put(name string, result health.Result, t time.Time) *cachedHealthCheckResult {
+	cached := &cachedHealthCheckResult{result, t}
+	c.mu.Lock()
+	if c.results == nil {
+		c.results = make(map[string]*cachedHealthCheckResult)
+	}
+	c.results[name] = cached
+	c.mu.Unlock()
+	return cached
+}
+
+// cacheAge returns the age of the oldest cached result.
+func cacheAge(results map[string]health.Result) time.Duration {
+	var oldest time.Time
+	for _, result := range results {
+		if cached, ok := result.(*cachedHealthCheckResult); ok {
+			if oldest.IsZero() || cached.time.Before(oldest) {
+				oldest = cached.time
+			}
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+// healthCheckCall is an in-flight or completed run of health checks.
+type healthCheckCall struct {
+	wg      sync.WaitGroup
+	results map[string]health.Result
+}
+
+// healthCheckGroup coalesces concurrent runs of health checks with the same
+// key so that only one of them is executed and others wait for its results.
+type healthCheckGroup struct {
+	mu    sync.Mutex
+	calls map[string]*healthCheckCall
+}
+
+func (g *healthCheckGroup) do(key string, fn func() map[string]health.Result) map[string]health.Result {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*healthCheckCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.results
+	}
+	c := &healthCheckCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.results = fn()
+	return c.results
+}
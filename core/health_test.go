@@ -0,0 +1,506 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/health"
+	"golang.org/x/net/context"
+)
+
+type testContextHealthCheck struct {
+	ctx context.Context
+}
+
+func (c *testContextHealthCheck) CheckContext(ctx context.Context) health.Result {
+	c.ctx = ctx
+	return health.ResultUnhealthy("down", nil)
+}
+
+func TestContextHealthCheck(t *testing.T) {
+	env := NewAdminEnvironment()
+	check := &testContextHealthCheck{}
+	env.RegisterHealthCheck("context", check)
+
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"context":{"Healthy":false,"Message":"down"}`) {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+	// Context is cancelled when the request is completed.
+	select {
+	case <-check.ctx.Done():
+	default:
+		t.Fatal("context is not cancelled")
+	}
+}
+
+// blockingHealthCheck waits until its context is done.
+type blockingHealthCheck struct{}
+
+func (c *blockingHealthCheck) CheckContext(ctx context.Context) health.Result {
+	<-ctx.Done()
+	return health.ResultUnhealthy(ctx.Err().Error(), nil)
+}
+
+func TestContextHealthCheckClosed(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.RegisterHealthCheck("blocking", &blockingHealthCheck{})
+
+	handler := &healthCheckHandler{env: env}
+	ctx, cancel := context.WithCancel(context.Background())
+	r, _ := http.NewRequest("GET", "/healthcheck?name=blocking", nil)
+	w := httptest.NewRecorder()
+	// Client closes the connection.
+	time.AfterFunc(10*time.Millisecond, cancel)
+	handler.ServeHTTP(w, r.WithContext(ctx))
+	if !strings.Contains(w.Body.String(), `"blocking":{"Healthy":false,"Message":"context canceled"}`) {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
+
+// releaseHealthCheck is healthy once released unless its context is done.
+type releaseHealthCheck struct {
+	count   int32
+	release chan struct{}
+}
+
+func (c *releaseHealthCheck) CheckContext(ctx context.Context) health.Result {
+	atomic.AddInt32(&c.count, 1)
+	select {
+	case <-ctx.Done():
+		return health.ResultUnhealthy(ctx.Err().Error(), nil)
+	case <-c.release:
+		return health.Healthy
+	}
+}
+
+func TestContextHealthCheckNotShared(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthCheckTimeout = time.Second
+	check := &releaseHealthCheck{release: make(chan struct{})}
+	env.RegisterHealthCheck("release", check)
+
+	handler := &healthCheckHandler{env: env}
+	serve := func(ctx context.Context, responses chan<- *httptest.ResponseRecorder) {
+		r, _ := http.NewRequest("GET", "/healthcheck?name=release", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+		responses <- w
+	}
+	waitRuns := func(n int32) {
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&check.count) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("unexpected number of runs: %d", atomic.LoadInt32(&check.count))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan *httptest.ResponseRecorder, 1)
+	go serve(ctx, first)
+	waitRuns(1)
+	second := make(chan *httptest.ResponseRecorder, 1)
+	go serve(context.Background(), second)
+	waitRuns(2)
+
+	// The first client closes the connection.
+	cancel()
+	if w := <-first; w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	close(check.release)
+	if w := <-second; w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+type testHealthCheck struct {
+	result health.Result
+}
+
+func (c *testHealthCheck) Check() health.Result {
+	return c.result
+}
+
+func TestHealthCheckPlainText(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthChecks.Unregister(DefaultHealthCheckName)
+	env.HealthChecks.Register("b", &testHealthCheck{health.ResultUnhealthy("down", errors.New("timeout"))})
+	env.HealthChecks.Register("a", &testHealthCheck{health.Healthy})
+
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	r.Header.Set("Accept", "text/plain")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w.Body.String() != "* a: OK\n! b: ERROR down (timeout)\n" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestHealthCheckBuildInfo(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.BuildInfo = &BuildInfo{Version: "1.0.0", Commit: "abc123", Date: "2016-01-02"}
+	env.HealthChecks.Register("a", &testHealthCheck{health.Healthy})
+
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w.Header().Get("X-Build-Info") != "1.0.0 abc123 2016-01-02" {
+		t.Fatalf("unexpected header %v", w.Header())
+	}
+	var output struct {
+		Build BuildInfo `json:"build"`
+		A     healthCheckResult
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+		t.Fatal(err)
+	}
+	if output.Build != *env.BuildInfo || !output.A.Healthy {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
+
+// brokenResponseWriter fails all writes as if the client has gone.
+type brokenResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *brokenResponseWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestHealthCheckWriteError(t *testing.T) {
+	var buf bytes.Buffer
+	logger := gol.GetLogger(adminLoggerName).(*gol.DefaultLogger)
+	level := logger.Level()
+	logger.SetLevel(gol.LevelDebug)
+	logger.SetAppender(gol.NewAppender(&buf))
+	defer func() {
+		logger.SetLevel(level)
+		logger.SetAppender(nil)
+	}()
+
+	env := NewAdminEnvironment()
+	env.HealthChecks.Register("a", &testHealthCheck{health.Healthy})
+	handler := &healthCheckHandler{env: env}
+	for _, accept := range []string{"application/json", "text/plain"} {
+		buf.Reset()
+		w := &brokenResponseWriter{httptest.NewRecorder()}
+		r, _ := http.NewRequest("GET", "/healthcheck", nil)
+		r.Header.Set("Accept", accept)
+		handler.ServeHTTP(w, r)
+		if !strings.Contains(buf.String(), "healthcheck: error writing response: broken pipe") {
+			t.Fatalf("unexpected log for %s: %q", accept, buf.String())
+		}
+	}
+}
+
+func TestHealthCheckBuildInfoConflict(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.ServerHandler = newTestServerHandler("")
+	env.HealthChecks.Register("build", &testHealthCheck{health.Healthy})
+	if err := env.checkHealthChecks(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	env.BuildInfo = &BuildInfo{Version: "1.0.0"}
+	err := env.onStarting()
+	if err == nil || err.Error() != `core: health check name "build" is reserved for build info` {
+		t.Fatalf("unexpected error %v", err)
+	}
+	env.HealthChecks.Unregister("build")
+	env.RegisterHealthCheck("build", &blockingHealthCheck{})
+	if err = env.checkHealthChecks(); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestHealthCheckByName(t *testing.T) {
+	env := NewAdminEnvironment()
+	a := &countHealthCheck{}
+	b := &countHealthCheck{}
+	env.HealthChecks.Register("a", a)
+	env.HealthChecks.Register("b", b)
+	env.RegisterHealthCheck("c", &testContextHealthCheck{})
+
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck?name=a&name=c", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if a.count != 1 || b.count != 0 {
+		t.Fatalf("unexpected health checks run: a=%d b=%d", a.count, b.count)
+	}
+	if !strings.Contains(w.Body.String(), `"a":`) || !strings.Contains(w.Body.String(), `"c":`) ||
+		strings.Contains(w.Body.String(), `"b":`) {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/healthcheck?name=a&name=d", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+type countHealthCheck struct {
+	count int
+}
+
+func (c *countHealthCheck) Check() health.Result {
+	c.count++
+	return health.Healthy
+}
+
+type slowHealthCheck struct {
+	duration time.Duration
+}
+
+func (c *slowHealthCheck) Check() health.Result {
+	time.Sleep(c.duration)
+	return health.Healthy
+}
+
+func (c *slowHealthCheck) CheckContext(ctx context.Context) health.Result {
+	<-ctx.Done()
+	return health.ResultUnhealthy("cancelled", ctx.Err())
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthCheckTimeout = 50 * time.Millisecond
+	env.HealthChecks.Register("fast", &testHealthCheck{health.Healthy})
+	env.HealthChecks.Register("slow1", &slowHealthCheck{time.Second})
+	env.HealthChecks.Register("slow2", &slowHealthCheck{time.Second})
+	env.RegisterHealthCheck("slow3", &slowHealthCheck{})
+
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	start := time.Now()
+	handler.ServeHTTP(w, r)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("health checks are not timed out: %v", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"fast":{"Healthy":true}`) ||
+		!strings.Contains(body, `"slow1":{"Healthy":false,"Message":"timed out"}`) ||
+		!strings.Contains(body, `"slow2":{"Healthy":false,"Message":"timed out"}`) ||
+		!strings.Contains(body, `"slow3":{"Healthy":false,`) {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+// stuckHealthCheck blocks until released and counts its runs.
+type stuckHealthCheck struct {
+	count   int32
+	release chan struct{}
+}
+
+func (c *stuckHealthCheck) Check() health.Result {
+	atomic.AddInt32(&c.count, 1)
+	<-c.release
+	return health.Healthy
+}
+
+func TestHealthCheckTimeoutStillRunning(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthCheckTimeout = 20 * time.Millisecond
+	check := &stuckHealthCheck{release: make(chan struct{})}
+	env.HealthChecks.Register("stuck", check)
+
+	handler := &healthCheckHandler{env: env}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/healthcheck", nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("unexpected code %v", w.Code)
+		}
+	}
+	if n := atomic.LoadInt32(&check.count); n != 1 {
+		t.Fatalf("unexpected number of runs: %d", n)
+	}
+	close(check.release)
+	// The result of the run is returned once it completes.
+	env.HealthCheckTimeout = time.Second
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+type panicHealthCheck struct{}
+
+func (c *panicHealthCheck) Check() health.Result {
+	panic("database is gone")
+}
+
+func TestHealthCheckTimeoutPanic(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthCheckTimeout = time.Second
+	env.HealthChecks.Register("panic", &panicHealthCheck{})
+
+	handler := &healthCheckHandler{env: env}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/healthcheck?name=panic", nil)
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("health check is blocked: %v", elapsed)
+		}
+		if w.Code != http.StatusServiceUnavailable ||
+			!strings.Contains(w.Body.String(), `"panic":{"Healthy":false,"Message":"panic","Cause":"database is gone"}`) {
+			t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHealthCheckCoalesce(t *testing.T) {
+	env := NewAdminEnvironment()
+	check := &stuckHealthCheck{release: make(chan struct{})}
+	env.HealthChecks.Register("stuck", check)
+
+	handler := &healthCheckHandler{env: env}
+	const n = 5
+	codes := make(chan int, n)
+	serve := func(url string) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", url, nil)
+		handler.ServeHTTP(w, r)
+		codes <- w.Code
+	}
+	go serve("/healthcheck")
+	for atomic.LoadInt32(&check.count) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i < n; i++ {
+		go serve("/healthcheck")
+	}
+	// Give other requests time to join the run in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(check.release)
+	for i := 0; i < n; i++ {
+		if code := <-codes; code != http.StatusOK {
+			t.Fatalf("unexpected code %v", code)
+		}
+	}
+	if c := atomic.LoadInt32(&check.count); c != 1 {
+		t.Fatalf("unexpected number of runs: %d", c)
+	}
+	// Runs are not shared after completed.
+	serve("/healthcheck?name=stuck")
+	if code := <-codes; code != http.StatusOK {
+		t.Fatalf("unexpected code %v", code)
+	}
+	if c := atomic.LoadInt32(&check.count); c != 2 {
+		t.Fatalf("unexpected number of runs: %d", c)
+	}
+}
+
+func TestHealthCheckCache(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.HealthCheckCacheTTL = time.Minute
+	a := &countHealthCheck{}
+	b := &countHealthCheck{}
+	env.HealthChecks.Register("a", a)
+	env.HealthChecks.Register("b", b)
+
+	handler := &healthCheckHandler{env: env}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/healthcheck?name=a", nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected code %v", w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	if a.count != 1 || b.count != 1 {
+		t.Fatalf("unexpected health checks run: a=%d b=%d", a.count, b.count)
+	}
+	// Make results stale
+	handler.cache.results["a"].time = time.Now().Add(-30 * time.Second)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Age") != "30" {
+		t.Fatalf("unexpected age %v", w.Header().Get("Age"))
+	}
+	if !strings.Contains(w.Body.String(), `"a":{"Healthy":true,"Age":"30`) {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+	handler.cache.results["a"].time = time.Now().Add(-time.Minute)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if a.count != 2 || b.count != 1 {
+		t.Fatalf("unexpected health checks run: a=%d b=%d", a.count, b.count)
+	}
+}
+
+type testHealthCheckWithContext struct {
+	testHealthCheck
+	testContextHealthCheck
+}
+
+func TestEnvironmentAddHealthCheck(t *testing.T) {
+	env := NewEnvironment()
+	env.AddHealthCheck("database", &testHealthCheck{health.Healthy})
+	env.AddHealthCheck("upstream", &testHealthCheckWithContext{testHealthCheck{health.Healthy}, testContextHealthCheck{}})
+
+	handler := &healthCheckHandler{env: env.Admin}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	body := w.Body.String()
+	if !strings.Contains(body, `"database":{"Healthy":true`) || !strings.Contains(body, `"upstream":{"Healthy":false,"Message":"down"}`) {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+func TestDefaultHealthCheck(t *testing.T) {
+	env := NewAdminEnvironment()
+	names := env.HealthChecks.Names()
+	if len(names) != 1 || names[0] != DefaultHealthCheckName {
+		t.Fatalf("unexpected health checks %v", names)
+	}
+	handler := &healthCheckHandler{env: env}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/healthcheck", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v: %v", w.Code, w.Body.String())
+	}
+}
@@ -1,6 +1,9 @@
 package core
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/goburrow/gol"
 )
 
@@ -19,8 +22,17 @@ type Managed interface {
 	Stop() error
 }
 
+// LifecycleEnvironment starts managed objects in the order they are added
+// before the server begins serving and stops them in reversed order after
+// the server has stopped.
 type LifecycleEnvironment struct {
+	mu             sync.Mutex
 	managedObjects []Managed
+	// startedObjects are objects which have been started successfully.
+	startedObjects []Managed
+	started        bool
+	// preShutdownHooks are called before the server drains connections.
+	preShutdownHooks []func()
 }
 
 // NewLifecycleEnvironment allocates and returns a new LifecycleEnvironment.
@@ -29,31 +41,111 @@ func NewLifecycleEnvironment() *LifecycleEnvironment {
 }
 
 // Manage adds the given object to the list of objects managed by the server's
-// lifecycle. Manage is not concurrent-safe.
+// lifecycle. If the server has already started, the object is started
+// immediately.
 func (env *LifecycleEnvironment) Manage(obj Managed) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
 	env.managedObjects = append(env.managedObjects, obj)
+	if env.started {
+		if err := env.start(obj); err != nil {
+			gol.GetLogger(lifecycleLoggerName).Warn("error starting a managed object: %v", err)
+		}
+	}
+}
+
+// Unmanage removes the given object from the lifecycle without stopping it,
+// e.g. when it has been replaced and stopped by the application.
+func (env *LifecycleEnvironment) Unmanage(obj Managed) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	env.managedObjects = removeManaged(env.managedObjects, obj)
+	env.startedObjects = removeManaged(env.startedObjects, obj)
+}
+
+func removeManaged(objects []Managed, obj Managed) []Managed {
+	for i, o := range objects {
+		if o == obj {
+			return append(objects[:i], objects[i+1:]...)
+		}
+	}
+	return objects
+}
+
+// AddPreShutdownHook adds a function which is called when the server starts
+// shutting down, while it is still accepting requests and before in-flight
+// requests are drained. For example, a hook can fail the readiness health
+// check and wait for load balancers to deregister the server.
+func (env *LifecycleEnvironment) AddPreShutdownHook(hook func()) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	env.preShutdownHooks = append(env.preShutdownHooks, hook)
+}
+
+// RunPreShutdownHooks calls pre-shutdown hooks in the order they are added.
+// It is called by the server.
+func (env *LifecycleEnvironment) RunPreShutdownHooks() {
+	env.mu.Lock()
+	hooks := append([]func(){}, env.preShutdownHooks...)
+	env.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
 }
 
 // starting indicates the environment that the application is going to start.
-func (env *LifecycleEnvironment) onStarting() {
-	logger := gol.GetLogger(lifecycleLoggerName)
+// If a managed object fails to start, objects which have been started are
+// stopped.
+func (env *LifecycleEnvironment) onStarting() error {
+	env.mu.Lock()
+	defer env.mu.Unlock()
+
+	env.started = true
 
 	// Starting managed objects in order.
-	for i, _ := range env.managedObjects {
-		if err := env.managedObjects[i].Start(); err != nil {
-			logger.Warn("error starting a managed object: %v", err)
+	for _, obj := range env.managedObjects {
+		if err := env.start(obj); err != nil {
+			gol.GetLogger(lifecycleLoggerName).Error("error starting a managed object: %v", err)
+			env.stopAll()
+			env.started = false
+			return fmt.Errorf("lifecycle: could not start %T: %v", obj, err)
 		}
 	}
+	return nil
 }
 
 // stopped indicates the environment that the application has stopped.
 func (env *LifecycleEnvironment) onStopped() {
-	logger := gol.GetLogger(lifecycleLoggerName)
+	env.mu.Lock()
+	defer env.mu.Unlock()
 
-	// Stopping managed objects in reversed order.
-	for i := len(env.managedObjects) - 1; i >= 0; i-- {
-		if err := env.managedObjects[i].Stop(); err != nil {
+	env.started = false
+	env.stopAll()
+}
+
+// start starts the object and records it for stopping.
+func (env *LifecycleEnvironment) start(obj Managed) error {
+	gol.GetLogger(lifecycleLoggerName).Debug("starting %T", obj)
+	if err := obj.Start(); err != nil {
+		return err
+	}
+	env.startedObjects = append(env.startedObjects, obj)
+	return nil
+}
+
+// stopAll stops started objects in reversed order.
+func (env *LifecycleEnvironment) stopAll() {
+	logger := gol.GetLogger(lifecycleLoggerName)
+	for i := len(env.startedObjects) - 1; i >= 0; i-- {
+		obj := env.startedObjects[i]
+		logger.Debug("stopping %T", obj)
+		if err := obj.Stop(); err != nil {
 			logger.Warn("error stopping a managed object: %v", err)
 		}
 	}
+	env.startedObjects = nil
 }
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testManaged struct {
+	name     string
+	events   *[]string
+	startErr error
+}
+
+func (m *testManaged) Start() error {
+	if m.startErr != nil {
+		return m.startErr
+	}
+	*m.events = append(*m.events, "start "+m.name)
+	return nil
+}
+
+func (m *testManaged) Stop() error {
+	*m.events = append(*m.events, "stop "+m.name)
+	return nil
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var events []string
+	env := NewLifecycleEnvironment()
+	env.Manage(&testManaged{name: "1", events: &events})
+	env.Manage(&testManaged{name: "2", events: &events})
+	if err := env.onStarting(); err != nil {
+		t.Fatal(err)
+	}
+	// Started immediately
+	env.Manage(&testManaged{name: "3", events: &events})
+	env.onStopped()
+
+	expected := []string{"start 1", "start 2", "start 3", "stop 3", "stop 2", "stop 1"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestLifecycleStartFailure(t *testing.T) {
+	var events []string
+	env := NewLifecycleEnvironment()
+	env.Manage(&testManaged{name: "1", events: &events})
+	env.Manage(&testManaged{name: "2", events: &events})
+	env.Manage(&testManaged{name: "3", events: &events, startErr: errors.New("failed")})
+	env.Manage(&testManaged{name: "4", events: &events})
+	if err := env.onStarting(); err == nil {
+		t.Fatal("error expected")
+	}
+	expected := []string{"start 1", "start 2", "stop 2", "stop 1"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	// Objects are not stopped twice.
+	env.onStopped()
+	if !reflect.DeepEqual(expected, events) {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestLifecyclePreShutdownHooks(t *testing.T) {
+	var events []string
+	env := NewLifecycleEnvironment()
+	env.AddPreShutdownHook(func() { events = append(events, "1") })
+	env.AddPreShutdownHook(func() { events = append(events, "2") })
+	env.RunPreShutdownHooks()
+	if !reflect.DeepEqual([]string{"1", "2"}, events) {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestLifecycleUnmanage(t *testing.T) {
+	var events []string
+	env := NewLifecycleEnvironment()
+	m1 := &testManaged{name: "1", events: &events}
+	m2 := &testManaged{name: "2", events: &events}
+	env.Manage(m1)
+	env.Manage(m2)
+	if err := env.onStarting(); err != nil {
+		t.Fatal(err)
+	}
+	env.Unmanage(m1)
+	env.onStopped()
+
+	expected := []string{"start 1", "start 2", "stop 2"}
+	if !reflect.DeepEqual(expected, events) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if len(env.managedObjects) != 1 || env.managedObjects[0] != m2 {
+		t.Fatalf("unexpected managed objects %v", env.managedObjects)
+	}
+}
@@ -1,6 +1,249 @@
 package core
 
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+const (
+	internalMetricsPrefix = "gomelon."
+
+	// Timers track durations from 1ms to 1 hour.
+	timerMinMillis = 1
+	timerMaxMillis = 1000 * 60 * 60
+	timerSigFigs   = 3
+)
+
+// internalMetrics is set when internal metrics are enabled.
+var internalMetrics int32
+
+// exemplarsEnabled is set when exemplars are recorded.
+var exemplarsEnabled int32
+
+var (
+	exemplarsMu sync.Mutex
+	exemplars   = make(map[string]Exemplar)
+
+	histogramsMu sync.Mutex
+	histograms   = make(map[string]*BucketHistogram)
+)
+
 // MetricsFactory is a factory for configuring the metrics for the environment.
 type MetricsFactory interface {
 	Configure(*Environment) error
 }
+
+// EnableInternalMetrics enables or disables metrics of the framework itself,
+// which are counters with "gomelon." prefix, e.g. gomelon.tasks.invocations.
+// They are disabled by default.
+func EnableInternalMetrics(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&internalMetrics, v)
+}
+
+// AddInternalMetric increases the internal counter with the given name by n
+// if internal metrics are enabled.
+func AddInternalMetric(name string, n uint64) {
+	if atomic.LoadInt32(&internalMetrics) != 0 {
+		metrics.Counter(internalMetricsPrefix + name).AddN(n)
+	}
+}
+
+// Exemplar is a sample of a metric taken from a traced request, which links
+// the metric to the trace in compatible backends.
+type Exemplar struct {
+	TraceID string
+	Value   float64
+	Time    time.Time
+}
+
+// EnableExemplars enables or disables recording exemplars. They are disabled
+// by default.
+func EnableExemplars(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&exemplarsEnabled, v)
+}
+
+// ExemplarsEnabled returns true if exemplars are recorded.
+func ExemplarsEnabled() bool {
+	return atomic.LoadInt32(&exemplarsEnabled) != 0
+}
+
+// SetExemplar replaces the exemplar of the metric with the given name if
+// exemplars are enabled.
+func SetExemplar(name string, exemplar Exemplar) {
+	if !ExemplarsEnabled() {
+		return
+	}
+	exemplarsMu.Lock()
+	exemplars[name] = exemplar
+	exemplarsMu.Unlock()
+}
+
+// Exemplars returns a copy of the latest exemplars by metric names.
+func Exemplars() map[string]Exemplar {
+	exemplarsMu.Lock()
+	defer exemplarsMu.Unlock()
+	m := make(map[string]Exemplar, len(exemplars))
+	for name, exemplar := range exemplars {
+		m[name] = exemplar
+	}
+	return m
+}
+
+// BucketHistogram counts observations in buckets of the given upper bounds.
+// Unlike histograms of percentiles, it is exposed as a histogram in
+// Prometheus and OpenMetrics formats, where each bucket may have an exemplar.
+type BucketHistogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	// counts has an extra bucket for observations exceeding all bounds.
+	counts    []uint64
+	sum       float64
+	exemplars []*Exemplar
+}
+
+// HistogramSnapshot is a copy of a BucketHistogram. Counts are cumulative.
+type HistogramSnapshot struct {
+	Bounds    []float64
+	Counts    []uint64
+	Count     uint64
+	Sum       float64
+	Exemplars []*Exemplar
+}
+
+// Histogram returns the bucket histogram with the given name, creating it
+// with the given bounds in ascending order if necessary.
+func Histogram(name string, bounds []float64) *BucketHistogram {
+	histogramsMu.Lock()
+	defer histogramsMu.Unlock()
+	h, ok := histograms[name]
+	if !ok {
+		h = &BucketHistogram{
+			bounds:    bounds,
+			counts:    make([]uint64, len(bounds)+1),
+			exemplars: make([]*Exemplar, len(bounds)+1),
+		}
+		histograms[name] = h
+	}
+	return h
+}
+
+// Observe records the value. The exemplar, if not nil, replaces the one of
+// the bucket the value falls in when exemplars are enabled.
+func (h *BucketHistogram) Observe(value float64, exemplar *Exemplar) {
+	i := 0
+	for i < len(h.bounds) && value > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += value
+	if exemplar != nil && ExemplarsEnabled() {
+		h.exemplars[i] = exemplar
+	}
+	h.mu.Unlock()
+}
+
+func (h *BucketHistogram) snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HistogramSnapshot{
+		Bounds:    h.bounds,
+		Counts:    make([]uint64, len(h.counts)),
+		Sum:       h.sum,
+		Exemplars: make([]*Exemplar, len(h.exemplars)),
+	}
+	for i, n := range h.counts {
+		s.Count += n
+		s.Counts[i] = s.Count
+	}
+	copy(s.Exemplars, h.exemplars)
+	return s
+}
+
+// Histograms returns snapshots of all bucket histograms by their names.
+func Histograms() map[string]HistogramSnapshot {
+	histogramsMu.Lock()
+	defer histogramsMu.Unlock()
+	m := make(map[string]HistogramSnapshot, len(histograms))
+	for name, h := range histograms {
+		m[name] = h.snapshot()
+	}
+	return m
+}
+
+// RemoveHistogram removes the bucket histogram with the given name.
+func RemoveHistogram(name string) {
+	histogramsMu.Lock()
+	delete(histograms, name)
+	histogramsMu.Unlock()
+}
+
+// MetricRegistry provides metrics for applications. Metrics are shared
+// globally and displayed in the metrics admin endpoint.
+type MetricRegistry struct {
+	mu     sync.Mutex
+	timers map[string]*Timer
+}
+
+// NewMetricRegistry allocates and returns a new MetricRegistry.
+func NewMetricRegistry() *MetricRegistry {
+	return &MetricRegistry{
+		timers: make(map[string]*Timer),
+	}
+}
+
+// Counter returns the counter with the given name.
+func (r *MetricRegistry) Counter(name string) metrics.Counter {
+	return metrics.Counter(name)
+}
+
+// Gauge registers the function f providing value of the gauge with the
+// given name.
+func (r *MetricRegistry) Gauge(name string, f func() int64) {
+	metrics.Gauge(name).SetFunc(f)
+}
+
+// Timer returns the timer with the given name, creating it if necessary.
+func (r *MetricRegistry) Timer(name string) *Timer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timer, ok := r.timers[name]
+	if !ok {
+		timer = &Timer{
+			histogram: metrics.NewHistogram(name, timerMinMillis, timerMaxMillis, timerSigFigs),
+		}
+		r.timers[name] = timer
+	}
+	return timer
+}
+
+// Timer records durations in milliseconds. Its percentiles are displayed as
+// gauges, e.g. Name.P99.
+type Timer struct {
+	histogram *metrics.Histogram
+}
+
+// Update records the given duration.
+func (t *Timer) Update(d time.Duration) {
+	_ = t.histogram.RecordValue(int64(d / time.Millisecond))
+}
+
+// Time calls f and records its duration.
+func (t *Timer) Time(f func()) {
+	start := time.Now()
+	defer func() {
+		t.Update(time.Since(start))
+	}()
+	f()
+}
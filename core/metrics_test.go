@@ -0,0 +1,76 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestInternalMetrics(t *testing.T) {
+	AddInternalMetric("test", 1)
+	counters, _ := metrics.Snapshot()
+	if _, ok := counters["gomelon.test"]; ok {
+		t.Fatal("internal metrics must be disabled by default")
+	}
+	EnableInternalMetrics(true)
+	defer EnableInternalMetrics(false)
+	AddInternalMetric("test", 2)
+	counters, _ = metrics.Snapshot()
+	if counters["gomelon.test"] != 2 {
+		t.Fatalf("unexpected counters %v", counters)
+	}
+}
+
+func TestMetricRegistry(t *testing.T) {
+	registry := NewMetricRegistry()
+	registry.Counter("Test.Registry.Counter").AddN(2)
+	registry.Gauge("Test.Registry.Gauge", func() int64 { return 3 })
+	counters, gauges := metrics.Snapshot()
+	if counters["Test.Registry.Counter"] != 2 || gauges["Test.Registry.Gauge"] != 3 {
+		t.Fatalf("unexpected metrics %v %v", counters, gauges)
+	}
+	timer := registry.Timer("Test.Registry.Timer")
+	if registry.Timer("Test.Registry.Timer") != timer {
+		t.Fatal("timer is not reused")
+	}
+	called := false
+	timer.Time(func() {
+		called = true
+	})
+	if !called {
+		t.Fatal("function is not called")
+	}
+	timer.Update(10 * time.Millisecond)
+	_, gauges = metrics.Snapshot()
+	if _, ok := gauges["Test.Registry.Timer.P99"]; !ok {
+		t.Fatalf("unexpected gauges %v", gauges)
+	}
+}
+
+func TestBucketHistogram(t *testing.T) {
+	EnableExemplars(true)
+	defer EnableExemplars(false)
+	defer RemoveHistogram("Test.Histogram")
+
+	h := Histogram("Test.Histogram", []float64{1, 2})
+	if Histogram("Test.Histogram", nil) != h {
+		t.Fatal("histogram is not reused")
+	}
+	h.Observe(0.5, nil)
+	h.Observe(2, &Exemplar{TraceID: "a", Value: 2})
+	h.Observe(3, nil)
+	s := Histograms()["Test.Histogram"]
+	if s.Count != 3 || s.Sum != 5.5 || len(s.Counts) != 3 ||
+		s.Counts[0] != 1 || s.Counts[1] != 2 || s.Counts[2] != 3 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+	if s.Exemplars[0] != nil || s.Exemplars[1] == nil || s.Exemplars[1].TraceID != "a" || s.Exemplars[2] != nil {
+		t.Fatalf("unexpected exemplars %+v", s.Exemplars)
+	}
+	EnableExemplars(false)
+	h.Observe(0.1, &Exemplar{TraceID: "b"})
+	if e := Histograms()["Test.Histogram"].Exemplars[0]; e != nil {
+		t.Fatalf("unexpected exemplar %+v", e)
+	}
+}
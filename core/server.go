@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 
 	"github.com/goburrow/gol"
 )
@@ -27,6 +28,15 @@ type ServerHandler interface {
 	PathPrefix() string
 }
 
+// FilterHandler is implemented by ServerHandler which supports filters, e.g.
+// server.Handler.
+type FilterHandler interface {
+	// AddFilter adds the filter to the end of the filter chain.
+	AddFilter(filter interface{}) error
+	// InsertFilter inserts the filter before the filter with the given name.
+	InsertFilter(filter interface{}, name string) error
+}
+
 // ServerFactory builds Server with given configuration and environment.
 type ServerFactory interface {
 	Build(environment *Environment) (Server, error)
@@ -35,8 +45,13 @@ type ServerFactory interface {
 // ServerEnvironment contains handlers for server and resources.
 type ServerEnvironment struct {
 	// ServerHandler belongs to the Server created by ServerFactory.
-	// The default implementation is DefaultServerHandler.
+	// The default implementation is server.Handler, which runs its filter
+	// chain before dispatching requests to the router.
 	ServerHandler ServerHandler
+	// HandlerWrapper is applied to the top-level application handler by the
+	// ServerFactory, before it is attached to connectors. The returned handler
+	// is executed before all filters.
+	HandlerWrapper func(http.Handler) http.Handler
 
 	components       []interface{}
 	resourceHandlers []ResourceHandler
@@ -65,12 +80,13 @@ func (env *ServerEnvironment) LogEndpoint(method, path string, component interfa
 		method, env.ServerHandler.PathPrefix(), path, component)
 }
 
-func (env *ServerEnvironment) onStarting() {
+func (env *ServerEnvironment) onStarting() error {
 	for _, component := range env.components {
 		env.handle(component)
 	}
 	env.logResources()
 	env.logEndpoints()
+	return nil
 }
 
 func (env *ServerEnvironment) onStopped() {
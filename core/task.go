@@ -1,7 +1,26 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	defaultTaskMethod = "POST"
+
+	maxTaskBodySize = 1 << 20
 )
 
 // Task is simply a HTTP Handler.
@@ -9,3 +28,276 @@ type Task interface {
 	Name() string
 	http.Handler
 }
+
+// MethodTask is a Task which supports HTTP methods other than POST, e.g. GET
+// for read-only tasks.
+type MethodTask interface {
+	Task
+	// Methods returns the HTTP methods the task is registered for.
+	Methods() []string
+}
+
+// TimeoutTask is a Task which has its own maximum running duration instead of
+// AdminEnvironment.TaskTimeout. Zero means no timeout.
+type TimeoutTask interface {
+	Task
+	Timeout() time.Duration
+}
+
+// TaskFunc runs a task, writing its output to w. It returns an error if the
+// task fails.
+type TaskFunc func(w io.Writer, r *http.Request) error
+
+// NewTask returns a Task which runs fn. Output of fn is sent with 200 OK if
+// it succeeds, otherwise 500 Internal Server Error is responded with the
+// error message.
+func NewTask(name string, fn TaskFunc) Task {
+	return &funcTask{name, fn}
+}
+
+// funcTask is a Task created by NewTask.
+type funcTask struct {
+	name string
+	fn   TaskFunc
+}
+
+func (t *funcTask) Name() string {
+	return t.name
+}
+
+func (t *funcTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := t.fn(&buf, r); err != nil {
+		gol.GetLogger(adminLoggerName).Warn("task %s failed: %v", t.name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// tasksHandler lists all registered tasks in JSON, or plain text if it is
+// requested in Accept header.
+type tasksHandler struct {
+	env *AdminEnvironment
+}
+
+// taskInfo is the JSON representation of a task.
+type taskInfo struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+}
+
+func (handler *tasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+	if acceptsPlainText(r, false) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, handler.env.taskEndpoints())
+		return
+	}
+	tasks := make([]taskInfo, 0, len(handler.env.tasks))
+	for _, task := range handler.env.tasks {
+		tasks = append(tasks, taskInfo{
+			Name:    task.Name(),
+			Type:    fmt.Sprintf("%T", task),
+			Path:    handler.env.ServerHandler.PathPrefix() + taskPath(task),
+			Methods: taskMethods(task),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	handler.env.JSONEncoder.Encode(w, tasks)
+}
+
+// taskMethods returns HTTP methods of the task.
+func taskMethods(task Task) []string {
+	if t, ok := task.(MethodTask); ok {
+		return t.Methods()
+	}
+	return []string{defaultTaskMethod}
+}
+
+// taskTimeout returns maximum running duration of the task.
+func taskTimeout(task Task, defaultTimeout time.Duration) time.Duration {
+	if t, ok := task.(TimeoutTask); ok {
+		return t.Timeout()
+	}
+	return defaultTimeout
+}
+
+// countedTask counts task invocations in internal metrics.
+type countedTask struct {
+	task Task
+}
+
+func (t *countedTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	AddInternalMetric("tasks.invocations", 1)
+	t.task.ServeHTTP(w, r)
+}
+
+// timeoutTask runs the task with a timeout. The request context is cancelled
+// and 504 Gateway Timeout is responded when the task exceeds the timeout.
+type timeoutTask struct {
+	task    http.Handler
+	timeout time.Duration
+}
+
+func (t *timeoutTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), t.timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := util.NewTimeoutWriter(w)
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			// Panic is propagated to the caller's goroutine so it does not
+			// crash the process.
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		t.task.ServeHTTP(tw, r)
+		close(done)
+	}()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+	case <-ctx.Done():
+		if tw.TimeOut() && ctx.Err() == context.DeadlineExceeded {
+			http.Error(w, "Task timed out.", http.StatusGatewayTimeout)
+		}
+	}
+}
+
+// DecodeTaskParams decodes parameters of the task request into v, which is
+// a pointer to a struct or a map. JSON body is decoded if the request content
+// type is application/json, otherwise query and form-encoded parameters are
+// used. Struct fields are matched by their json tag or name, case-insensitive.
+func DecodeTaskParams(r *http.Request, v interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxTaskBodySize)).Decode(v); err != nil {
+			return fmt.Errorf("task: invalid JSON body: %v", err)
+		}
+		return nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("task: invalid parameters: %v", err)
+	}
+	return decodeForm(r.Form, v)
+}
+
+// decodeForm sets form values to the struct or map pointed by v.
+func decodeForm(form map[string][]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("task: non-pointer %T", v)
+	}
+	rv = rv.Elem()
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("task: unsupported type %T", v)
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		for name, values := range form {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := setFormValue(elem, values); err != nil {
+				return fmt.Errorf("task: invalid parameter %s: %v", name, err)
+			}
+			rv.SetMapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			for key, values := range form {
+				if strings.EqualFold(key, name) {
+					if err := setFormValue(rv.Field(i), values); err != nil {
+						return fmt.Errorf("task: invalid parameter %s: %v", key, err)
+					}
+					break
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("task: unsupported type %T", v)
+	}
+	return nil
+}
+
+// setFormValue converts values to the type of v. The first value is used if
+// v is not a slice.
+func setFormValue(v reflect.Value, values []string) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setFormValue(slice.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Interface:
+		if len(values) == 1 {
+			v.Set(reflect.ValueOf(values[0]))
+		} else {
+			v.Set(reflect.ValueOf(values))
+		}
+		return nil
+	}
+	var s string
+	if len(values) > 0 {
+		s = values[0]
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
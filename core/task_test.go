@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTaskParams struct {
+	Name    string
+	Enabled bool    `json:"enabled"`
+	Percent float64 `json:"percent"`
+	Groups  []int
+	Ignored string `json:"-"`
+}
+
+func TestDecodeTaskParamsForm(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/tasks/flag?name=feature",
+		strings.NewReader("enabled=true&percent=12.5&groups=1&groups=2&ignored=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var params testTaskParams
+	if err := DecodeTaskParams(r, &params); err != nil {
+		t.Fatal(err)
+	}
+	expected := testTaskParams{Name: "feature", Enabled: true, Percent: 12.5, Groups: []int{1, 2}}
+	if !reflect.DeepEqual(expected, params) {
+		t.Fatalf("unexpected params %+v", params)
+	}
+
+	r, _ = http.NewRequest("POST", "/tasks/flag", strings.NewReader("name=feature&groups=1&groups=2"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var m map[string]interface{}
+	if err := DecodeTaskParams(r, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[string]interface{}{"name": "feature", "groups": []string{"1", "2"}}, m) {
+		t.Fatalf("unexpected params %+v", m)
+	}
+
+	r, _ = http.NewRequest("POST", "/tasks/flag", strings.NewReader("enabled=maybe"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := DecodeTaskParams(r, &params); err == nil || !strings.Contains(err.Error(), "enabled") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDecodeTaskParamsJSON(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/tasks/flag",
+		strings.NewReader(`{"name":"feature","enabled":true,"percent":12.5,"groups":[1,2]}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	var params testTaskParams
+	if err := DecodeTaskParams(r, &params); err != nil {
+		t.Fatal(err)
+	}
+	expected := testTaskParams{Name: "feature", Enabled: true, Percent: 12.5, Groups: []int{1, 2}}
+	if !reflect.DeepEqual(expected, params) {
+		t.Fatalf("unexpected params %+v", params)
+	}
+
+	r, _ = http.NewRequest("POST", "/tasks/flag", strings.NewReader(`{"name":`))
+	r.Header.Set("Content-Type", "application/json")
+	if err := DecodeTaskParams(r, &params); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestTasksHandler(t *testing.T) {
+	env := NewAdminEnvironment()
+	env.AddTask(&testMethodTask{})
+	serverHandler := newTestServerHandler("/admin")
+	env.ServerHandler = serverHandler
+	env.onStarting()
+
+	handler := serverHandler.handlers["GET /tasks"]
+	if handler == nil {
+		t.Fatalf("GET /tasks is not registered: %v", serverHandler.handlers)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/admin/tasks", nil)
+	handler.ServeHTTP(w, r)
+	var tasks []taskInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil {
+		t.Fatal(err)
+	}
+	expected := []taskInfo{
+		{"gc", "*core.gcTask", "/admin/tasks/gc", []string{"POST"}},
+		{"test", "*core.testMethodTask", "/admin/tasks/test", []string{"GET", "PUT"}},
+	}
+	if !reflect.DeepEqual(expected, tasks) {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set("Accept", "text/plain")
+	handler.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), "POST    /admin/tasks/gc (*core.gcTask)\n") {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
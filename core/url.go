@@ -0,0 +1,34 @@
+package core
+
+import (
+	"net/http"
+)
+
+// BaseURL returns the scheme and host the client used to reach the server,
+// e.g. "https://example.com". Scheme and host of the request URL, which are
+// restored from trusted reverse proxies, take precedence over the connection.
+func BaseURL(r *http.Request) string {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		if r.TLS != nil {
+			scheme = "https"
+		} else {
+			scheme = "http"
+		}
+	}
+	host := r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	return scheme + "://" + host
+}
+
+// ExternalURL returns the absolute URL of the given path when the request
+// was forwarded by a trusted proxy. Otherwise the path is returned as is so
+// that clients resolve it against the URL they requested.
+func ExternalURL(r *http.Request, path string) string {
+	if r.URL.Scheme == "" && r.URL.Host == "" {
+		return path
+	}
+	return BaseURL(r) + path
+}
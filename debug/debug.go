@@ -73,7 +73,8 @@ func (h *pprofHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			pprof.Profile(w, r)
 		case "symbol":
 			pprof.Symbol(w, r)
-		// TODO: pprof.Trace
+		case "trace":
+			pprof.Trace(w, r)
 		default:
 			pprof.Handler(name).ServeHTTP(w, r)
 		}
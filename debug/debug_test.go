@@ -38,3 +38,30 @@ func TestBundle(t *testing.T) {
 		t.Fatalf("unexpected body %s", body)
 	}
 }
+
+func TestBundleProfiles(t *testing.T) {
+	env := core.NewEnvironment()
+	handler := server.NewHandler()
+	env.Admin.ServerHandler = handler
+
+	bundle := NewBundle()
+	bundle.Run(nil, env)
+
+	server := httptest.NewServer(handler.ServeMux)
+	defer server.Close()
+
+	for _, path := range []string{"cmdline", "heap", "goroutine?debug=1", "trace?seconds=0.05"} {
+		res, err := http.Get(server.URL + "/debug/pprof/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != 200 || len(body) == 0 {
+			t.Fatalf("unexpected response of %s: %+v %q", path, res, body)
+		}
+	}
+}
@@ -19,6 +19,8 @@ func (command *EnvironmentCommand) Run(bootstrap *core.Bootstrap) error {
 	command.Environment = core.NewEnvironment()
 	command.Environment.Name = bootstrap.Application.Name()
 	command.Environment.Validator = bootstrap.ValidatorFactory.Validator()
+	command.Environment.Admin.BuildInfo = bootstrap.BuildInfo
+	command.Environment.Server.HandlerWrapper = bootstrap.HandlerWrapper
 	// Config other factories that affect this environment.
 	if err := command.configuration.LoggingFactory().Configure(command.Environment); err != nil {
 		command.Environment.SetStopped()
@@ -4,7 +4,9 @@ Package gomelon provides a lightweight framework for building web services.
 package gomelon
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/goburrow/gomelon/configuration"
@@ -12,10 +14,20 @@ import (
 	"github.com/goburrow/gomelon/validation"
 )
 
-func printHelp(bootstrap *core.Bootstrap) {
-	fmt.Fprintln(os.Stdout, "Available commands:")
+const (
+	helpCommandName = "help"
+)
+
+// For testing
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+func printHelp(w io.Writer, bootstrap *core.Bootstrap) {
+	fmt.Fprintln(w, "Available commands:")
 	for _, command := range bootstrap.Commands() {
-		fmt.Fprintf(os.Stdout, "  %-20s\t%s\n", command.Name(), command.Description())
+		fmt.Fprintf(w, "  %-20s\t%s\n", command.Name(), command.Description())
 	}
 }
 
@@ -23,17 +35,25 @@ func printHelp(bootstrap *core.Bootstrap) {
 func Run(app core.Application, args []string) error {
 	bootstrap := core.NewBootstrap(app)
 	bootstrap.Arguments = args
-	bootstrap.ConfigurationFactory = &configuration.Factory{&Configuration{}}
+	bootstrap.ConfigurationFactory = &configuration.Factory{Configuration: &Configuration{}}
 	bootstrap.ValidatorFactory = &validation.Factory{}
+	// Available for all applications
+	bootstrap.AddCommand(&VersionCommand{})
 
 	app.Initialize(bootstrap)
-	if len(args) > 0 {
-		for _, command := range bootstrap.Commands() {
-			if command.Name() == args[0] {
-				return command.Run(bootstrap)
-			}
+	if len(args) == 0 {
+		printHelp(stderr, bootstrap)
+		return errors.New("gomelon: no command specified")
+	}
+	for _, command := range bootstrap.Commands() {
+		if command.Name() == args[0] {
+			return command.Run(bootstrap)
 		}
 	}
-	printHelp(bootstrap)
-	return nil
+	if args[0] == helpCommandName {
+		printHelp(stdout, bootstrap)
+		return nil
+	}
+	printHelp(stderr, bootstrap)
+	return fmt.Errorf("gomelon: unknown command %q", args[0])
 }
@@ -0,0 +1,209 @@
+package gomelon
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+type testCommand struct {
+	ran bool
+}
+
+func (c *testCommand) Name() string {
+	return "test"
+}
+
+func (c *testCommand) Description() string {
+	return "runs test"
+}
+
+func (c *testCommand) Run(*core.Bootstrap) error {
+	c.ran = true
+	return nil
+}
+
+type testApplication struct {
+	Application
+	command testCommand
+}
+
+func (app *testApplication) Initialize(bootstrap *core.Bootstrap) {
+	bootstrap.AddCommand(&app.command)
+}
+
+func runTest(args ...string) (*testApplication, string, string, error) {
+	app := &testApplication{}
+	out, errOut, err := runApplication(app, args...)
+	return app, out, errOut, err
+}
+
+// runApplication runs the application and returns its stdout and stderr.
+func runApplication(app core.Application, args ...string) (string, string, error) {
+	var outBuf, errBuf bytes.Buffer
+	defaultStdout, defaultStderr := stdout, stderr
+	stdout, stderr = &outBuf, &errBuf
+	defer func() {
+		stdout, stderr = defaultStdout, defaultStderr
+	}()
+	err := Run(app, args)
+	return outBuf.String(), errBuf.String(), err
+}
+
+func TestRunCommand(t *testing.T) {
+	app, out, errOut, err := runTest("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !app.command.ran || out != "" || errOut != "" {
+		t.Fatalf("unexpected result %v %q %q", app.command.ran, out, errOut)
+	}
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	app, out, errOut, err := runTest("unknown")
+	if err == nil || err.Error() != `gomelon: unknown command "unknown"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if app.command.ran || out != "" || !strings.Contains(errOut, "test") || !strings.Contains(errOut, "version") {
+		t.Fatalf("unexpected result %v %q %q", app.command.ran, out, errOut)
+	}
+}
+
+func TestRunNoCommand(t *testing.T) {
+	_, out, errOut, err := runTest()
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	if out != "" || !strings.HasPrefix(errOut, "Available commands:\n") {
+		t.Fatalf("unexpected output %q %q", out, errOut)
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	_, out, errOut, err := runTest("help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errOut != "" || !strings.HasPrefix(out, "Available commands:\n") || !strings.Contains(out, "runs test") {
+		t.Fatalf("unexpected output %q %q", out, errOut)
+	}
+}
+
+type dumpConfigApplication struct {
+	Application
+}
+
+func (app *dumpConfigApplication) Initialize(bootstrap *core.Bootstrap) {
+	bootstrap.AddCommand(&DumpConfigCommand{})
+}
+
+func TestDumpConfigCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	config := `server:
+  type: DefaultServer
+  applicationConnectors:
+  - type: http
+    addr: :8080
+  adminConnectors:
+  - type: http
+    addr: :8081
+  admin:
+    username: admin
+    password: secret
+`
+	if err = ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outBuf bytes.Buffer
+	defaultStdout := stdout
+	stdout = &outBuf
+	defer func() {
+		stdout = defaultStdout
+	}()
+	if err = Run(&dumpConfigApplication{}, []string{"dump-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	out := outBuf.String()
+	if !strings.Contains(out, "password: '****'") || !strings.Contains(out, "username: admin") ||
+		strings.Contains(out, "secret") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	config := `server:
+  type: DefaultServer
+  applicationConnectors:
+  - type: http
+    addr: :8080
+  adminConnectors:
+  - type: http
+    addr: :8081
+`
+	if err = ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, errOut, err := runApplication(&Application{}, "check", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Configuration is valid\n" || errOut != "" {
+		t.Fatalf("unexpected output %q %q", out, errOut)
+	}
+
+	config = `server:
+  type: DefaultServer
+  applicationConnectors:
+  - type: https
+    addr: :8443
+`
+	if err = ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, errOut, err = runApplication(&Application{}, "check", path)
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	if out != "" || !strings.HasPrefix(errOut, "Configuration is invalid: ") {
+		t.Fatalf("unexpected output %q %q", out, errOut)
+	}
+}
+
+type versionApplication struct {
+	Application
+}
+
+func (app *versionApplication) Initialize(bootstrap *core.Bootstrap) {
+	bootstrap.BuildInfo = &core.BuildInfo{Version: "1.0.0", Commit: "abc123"}
+}
+
+func TestVersionCommand(t *testing.T) {
+	app := &versionApplication{}
+	app.SetName("test-app")
+	out, errOut, err := runApplication(app, "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Name: test-app\nVersion: 1.0.0\nCommit: abc123\nDate: unknown\n"
+	if out != expected || errOut != "" {
+		t.Fatalf("unexpected output %q %q", out, errOut)
+	}
+}
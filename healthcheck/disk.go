@@ -0,0 +1,38 @@
+package healthcheck
+
+import (
+	"fmt"
+
+	"github.com/goburrow/health"
+)
+
+// DiskSpaceHealthCheck checks free space of the file system containing
+// the given path.
+type DiskSpaceHealthCheck struct {
+	path         string
+	minFreeBytes uint64
+}
+
+var _ health.HealthCheck = (*DiskSpaceHealthCheck)(nil)
+
+// NewDiskSpaceHealthCheck allocates and returns a new DiskSpaceHealthCheck
+// which is unhealthy when free space available in path is less than
+// minFreeBytes.
+func NewDiskSpaceHealthCheck(path string, minFreeBytes uint64) *DiskSpaceHealthCheck {
+	return &DiskSpaceHealthCheck{
+		path:         path,
+		minFreeBytes: minFreeBytes,
+	}
+}
+
+func (c *DiskSpaceHealthCheck) Check() health.Result {
+	free, err := freeDiskSpace(c.path)
+	if err != nil {
+		return health.ResultUnhealthy(fmt.Sprintf("could not get free space of %s", c.path), err)
+	}
+	if free < c.minFreeBytes {
+		return health.ResultUnhealthy(fmt.Sprintf("free space of %s is %d bytes, required %d bytes",
+			c.path, free, c.minFreeBytes), nil)
+	}
+	return health.Healthy
+}
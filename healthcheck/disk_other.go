@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd,!dragonfly,!windows
+
+package healthcheck
+
+import (
+	"errors"
+)
+
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("healthcheck: disk space is not supported on this platform")
+}
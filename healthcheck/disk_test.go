@@ -0,0 +1,30 @@
+package healthcheck
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiskSpaceHealthCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	result := NewDiskSpaceHealthCheck(dir, 1).Check()
+	if !result.Healthy() {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = NewDiskSpaceHealthCheck(dir, math.MaxUint64).Check()
+	if result.Healthy() || !strings.Contains(result.Message(), "required 18446744073709551615 bytes") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = NewDiskSpaceHealthCheck(dir+"/notfound", 1).Check()
+	if result.Healthy() || result.Cause() == nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
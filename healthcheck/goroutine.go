@@ -0,0 +1,84 @@
+package healthcheck
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/goburrow/health"
+)
+
+const (
+	defaultGoroutineWindow    = 10
+	defaultGoroutineMaxGrowth = 100
+)
+
+// ThreadDeadlockHealthCheck detects goroutine leaks. It is unhealthy when
+// the number of goroutines exceeds the maximum, or it has increased in every
+// sample of the window by more than MaxGrowth in total. The number is
+// sampled each time the check runs.
+type ThreadDeadlockHealthCheck struct {
+	// Window is the number of recent samples kept. Default is used if it is
+	// less than 2.
+	Window int
+	// MaxGrowth is the total growth allowed when the number of goroutines
+	// keeps increasing over the window. Zero disables growth detection.
+	MaxGrowth int
+
+	maxGoroutines int
+	// For testing
+	numGoroutine func() int
+
+	mu      sync.Mutex
+	samples []int
+}
+
+var _ health.HealthCheck = (*ThreadDeadlockHealthCheck)(nil)
+
+// NewThreadDeadlockHealthCheck allocates and returns a new
+// ThreadDeadlockHealthCheck with the given maximum number of goroutines.
+func NewThreadDeadlockHealthCheck(maxGoroutines int) *ThreadDeadlockHealthCheck {
+	return &ThreadDeadlockHealthCheck{
+		Window:        defaultGoroutineWindow,
+		MaxGrowth:     defaultGoroutineMaxGrowth,
+		maxGoroutines: maxGoroutines,
+		numGoroutine:  runtime.NumGoroutine,
+	}
+}
+
+func (c *ThreadDeadlockHealthCheck) Check() health.Result {
+	n := c.numGoroutine()
+
+	window := c.Window
+	if window < 2 {
+		window = defaultGoroutineWindow
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.samples = append(c.samples, n)
+	if len(c.samples) > window {
+		c.samples = c.samples[len(c.samples)-window:]
+	}
+	if c.maxGoroutines > 0 && n > c.maxGoroutines {
+		return health.ResultUnhealthy(fmt.Sprintf("%d goroutines exceed maximum %d", n, c.maxGoroutines), nil)
+	}
+	if c.MaxGrowth > 0 && len(c.samples) == window && c.isGrowing() {
+		growth := n - c.samples[0]
+		if growth > c.MaxGrowth {
+			return health.ResultUnhealthy(fmt.Sprintf("goroutines increased by %d to %d in last %d checks",
+				growth, n, window), nil)
+		}
+	}
+	return health.Healthy
+}
+
+// isGrowing returns true if the number of goroutines increases in every
+// sample.
+func (c *ThreadDeadlockHealthCheck) isGrowing() bool {
+	for i := 1; i < len(c.samples); i++ {
+		if c.samples[i] <= c.samples[i-1] {
+			return false
+		}
+	}
+	return true
+}
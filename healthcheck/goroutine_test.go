@@ -0,0 +1,69 @@
+package healthcheck
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestThreadDeadlockHealthCheck(t *testing.T) {
+	n := runtime.NumGoroutine()
+	check := NewThreadDeadlockHealthCheck(n + 10)
+	if result := check.Check(); !result.Healthy() {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	for i := 0; i < 20; i++ {
+		go func() {
+			<-done
+		}()
+	}
+	result := check.Check()
+	if result.Healthy() || !strings.Contains(result.Message(), "exceed maximum") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestThreadDeadlockHealthCheckGrowth(t *testing.T) {
+	check := NewThreadDeadlockHealthCheck(0)
+	check.Window = 3
+	check.MaxGrowth = 10
+	count := 0
+	check.numGoroutine = func() int { return count }
+	for _, c := range []int{10, 15, 12, 20, 22} {
+		count = c
+		if result := check.Check(); !result.Healthy() {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	count = 32
+	result := check.Check()
+	if result.Healthy() || result.Message() != "goroutines increased by 12 to 32 in last 3 checks" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	// Decreased
+	count = 30
+	if result = check.Check(); !result.Healthy() {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestThreadDeadlockHealthCheckInvalidWindow(t *testing.T) {
+	for _, window := range []int{-1, 0, 1} {
+		check := NewThreadDeadlockHealthCheck(0)
+		check.Window = window
+		check.MaxGrowth = 1
+		count := 0
+		check.numGoroutine = func() int { return count }
+		for i := 0; i < defaultGoroutineWindow; i++ {
+			count += 10
+			check.Check()
+		}
+		count += 10
+		result := check.Check()
+		if result.Healthy() || !strings.Contains(result.Message(), "in last 10 checks") {
+			t.Fatalf("unexpected result %+v for window %d", result, window)
+		}
+	}
+}
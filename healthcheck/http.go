@@ -0,0 +1,76 @@
+/*
+Package healthcheck provides common health checks for applications.
+*/
+package healthcheck
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/goburrow/gomelon/core"
+	"github.com/goburrow/health"
+	"golang.org/x/net/context"
+)
+
+// HTTPHealthCheck checks an HTTP dependency by sending a GET request to its
+// URL. It is healthy when the response status is 2xx.
+type HTTPHealthCheck struct {
+	// Client is used to send requests, default is http.DefaultClient.
+	Client *http.Client
+
+	name    string
+	url     string
+	timeout time.Duration
+}
+
+var _ health.HealthCheck = (*HTTPHealthCheck)(nil)
+var _ core.ContextHealthCheck = (*HTTPHealthCheck)(nil)
+
+// NewHTTPHealthCheck allocates and returns a new HTTPHealthCheck for the
+// dependency with the given name. For example:
+//
+//	env.Admin.HealthChecks.Register("users", healthcheck.NewHTTPHealthCheck("users", url, 2*time.Second))
+func NewHTTPHealthCheck(name, url string, timeout time.Duration) *HTTPHealthCheck {
+	return &HTTPHealthCheck{
+		name:    name,
+		url:     url,
+		timeout: timeout,
+	}
+}
+
+// Check sends a request to the dependency.
+func (c *HTTPHealthCheck) Check() health.Result {
+	return c.CheckContext(context.Background())
+}
+
+// CheckContext sends a request to the dependency which is cancelled when
+// ctx is done or the timeout expires.
+func (c *HTTPHealthCheck) CheckContext(ctx context.Context) health.Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest("GET", c.url, nil)
+	if err != nil {
+		return health.ResultUnhealthy(fmt.Sprintf("%s: invalid request", c.name), err)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return health.ResultUnhealthy(fmt.Sprintf("%s is not reachable", c.name), err)
+	}
+	// Drain body so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return health.ResultUnhealthy(fmt.Sprintf("%s responded %d", c.name, resp.StatusCode), nil)
+	}
+	return health.Healthy
+}
@@ -0,0 +1,42 @@
+package healthcheck
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPHealthCheck(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+		}
+	}))
+	defer server.Close()
+
+	result := NewHTTPHealthCheck("upstream", server.URL+"/", time.Second).Check()
+	if !result.Healthy() {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = NewHTTPHealthCheck("upstream", server.URL+"/error", time.Second).Check()
+	if result.Healthy() || result.Message() != "upstream responded 500" || result.Cause() != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	start := time.Now()
+	result = NewHTTPHealthCheck("upstream", server.URL+"/slow", 50*time.Millisecond).Check()
+	if result.Healthy() || result.Message() != "upstream is not reachable" || result.Cause() == nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("timeout is not applied")
+	}
+}
@@ -1,10 +1,12 @@
 package logging
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
@@ -12,32 +14,6 @@ import (
 	golfile "github.com/goburrow/gol/file"
 	golrotation "github.com/goburrow/gol/file/rotation"
 	golfilter "github.com/goburrow/gol/filter"
-	golsyslog "github.com/goburrow/gol/syslog"
-)
-
-var (
-	facilities = map[string]golsyslog.Facility{
-		"KERN":     golsyslog.LOG_KERN,
-		"USER":     golsyslog.LOG_USER,
-		"MAIL":     golsyslog.LOG_MAIL,
-		"DAEMON":   golsyslog.LOG_DAEMON,
-		"AUTH":     golsyslog.LOG_AUTH,
-		"SYSLOG":   golsyslog.LOG_SYSLOG,
-		"LPR":      golsyslog.LOG_LPR,
-		"NEWS":     golsyslog.LOG_NEWS,
-		"UUCP":     golsyslog.LOG_UUCP,
-		"CRON":     golsyslog.LOG_CRON,
-		"AUTHPRIV": golsyslog.LOG_AUTHPRIV,
-		"FTP":      golsyslog.LOG_FTP,
-		"LOCAL0":   golsyslog.LOG_LOCAL0,
-		"LOCAL1":   golsyslog.LOG_LOCAL1,
-		"LOCAL2":   golsyslog.LOG_LOCAL2,
-		"LOCAL3":   golsyslog.LOG_LOCAL3,
-		"LOCAL4":   golsyslog.LOG_LOCAL4,
-		"LOCAL5":   golsyslog.LOG_LOCAL5,
-		"LOCAL6":   golsyslog.LOG_LOCAL6,
-		"LOCAL7":   golsyslog.LOG_LOCAL7,
-	}
 )
 
 // AppenderFactory is for creating gol.Appender.
@@ -45,6 +21,12 @@ type AppenderFactory interface {
 	Build(*core.Environment) (gol.Appender, error)
 }
 
+// managedAppender is an appender which needs to be started and stopped.
+type managedAppender interface {
+	gol.Appender
+	core.Managed
+}
+
 // getThreshold returns gol.LevelAll if threshold is empty.
 func getThreshold(threshold string) (gol.Level, error) {
 	if threshold == "" {
@@ -80,9 +62,57 @@ func (factory *filteredAppenderFactory) Build(appender gol.Appender) (gol.Append
 	return a, nil
 }
 
+// formatFactory is an abstract factory to create an appender writing
+// logging events in the configured format.
+type formatFactory struct {
+	// Format is the layout of log lines: "text" (default) or "json".
+	Format string
+}
+
+func (factory *formatFactory) newAppender(w io.Writer) (gol.Appender, error) {
+	switch factory.Format {
+	case "", "text":
+		return gol.NewAppender(w), nil
+	case "json":
+		return &jsonAppender{writer: w}, nil
+	default:
+		return nil, fmt.Errorf("logging: unsupported format %s", factory.Format)
+	}
+}
+
+// jsonEvent is a logging event in JSON format.
+type jsonEvent struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Logger  string `json:"logger"`
+	Message string `json:"message"`
+}
+
+// jsonAppender writes each logging event as a single line JSON object.
+type jsonAppender struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (a *jsonAppender) Append(event *gol.LoggingEvent) {
+	b, err := json.Marshal(&jsonEvent{
+		Time:    event.Time.Format(time.RFC3339),
+		Level:   gol.LevelString(event.Level),
+		Logger:  event.Name,
+		Message: event.Message,
+	})
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	a.writer.Write(append(b, '\n'))
+	a.mu.Unlock()
+}
+
 // ConsoleAppenderFactory provides an appender that writes logging events to the console.
 type ConsoleAppenderFactory struct {
 	filteredAppenderFactory
+	formatFactory
 
 	Target string
 }
@@ -99,7 +129,11 @@ func (factory *ConsoleAppenderFactory) Build(environment *core.Environment) (gol
 		return nil, fmt.Errorf("logging: unsupported target %s", factory.Target)
 	}
 
-	return factory.filteredAppenderFactory.Build(gol.NewAppender(writer))
+	appender, err := factory.formatFactory.newAppender(writer)
+	if err != nil {
+		return nil, err
+	}
+	return factory.filteredAppenderFactory.Build(appender)
 }
 
 // FileAppenderFactory provides an appender that writes logging events to file system.
@@ -142,24 +176,21 @@ func (factory *FileAppenderFactory) Build(environment *core.Environment) (gol.Ap
 }
 
 // SyslogAppenderFactory provides an appender that writes logging events to syslog.
+// Logging events are written to stderr while syslog is not reachable.
 type SyslogAppenderFactory struct {
 	filteredAppenderFactory
 
 	Network  string
 	Addr     string
 	Facility string
+	// Tag is the syslog tag of messages, default is the program name.
+	Tag string
 }
 
 func (factory *SyslogAppenderFactory) Build(environment *core.Environment) (gol.Appender, error) {
-	sa := golsyslog.NewAppender()
-	sa.Network = factory.Network
-	sa.Addr = factory.Addr
-	if factory.Facility != "" {
-		facility, ok := facilities[strings.ToUpper(factory.Facility)]
-		if !ok {
-			return nil, fmt.Errorf("logging: unsupported facility %s", factory.Facility)
-		}
-		sa.Facility = facility
+	sa, err := newSyslogAppender(factory.Network, factory.Addr, factory.Facility, factory.Tag)
+	if err != nil {
+		return nil, err
 	}
 	appender, err := factory.filteredAppenderFactory.Build(sa)
 	if err != nil {
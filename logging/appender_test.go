@@ -1,11 +1,14 @@
 package logging
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
 )
 
@@ -52,6 +55,31 @@ func TestConsoleLoggingWithInvalidArguments(t *testing.T) {
 	if err == nil {
 		t.Fatal("error must be thrown")
 	}
+	factory.Threshold = ""
+	factory.Format = "xml"
+	_, err = factory.Build(environment)
+	if err == nil || err.Error() != "logging: unsupported format xml" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	factory := &formatFactory{Format: "json"}
+	appender, err := factory.newAppender(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appender.Append(&gol.LoggingEvent{
+		Name:    "app",
+		Level:   gol.LevelWarn,
+		Time:    time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+		Message: `disk "data" is full`,
+	})
+	expected := `{"time":"2016-01-02T03:04:05Z","level":"WARN","logger":"app","message":"disk \"data\" is full"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
 }
 
 func TestFileLogging(t *testing.T) {
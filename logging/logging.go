@@ -5,7 +5,9 @@ package logging
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
@@ -32,9 +34,19 @@ var (
 	}
 )
 
+// loggerNames contains names of loggers which have been configured since gol
+// does not expose registered loggers.
+var loggerNames = struct {
+	sync.Mutex
+	names map[string]struct{}
+}{
+	names: map[string]struct{}{gol.RootLoggerName: struct{}{}},
+}
+
 func init() {
 	polytype.Register("ConsoleAppender", func() interface{} { return &ConsoleAppenderFactory{} })
 	polytype.Register("FileAppender", func() interface{} { return &FileAppenderFactory{} })
+	polytype.Register("RollingFileAppender", func() interface{} { return &RollingFileAppenderFactory{} })
 	polytype.Register("SyslogAppender", func() interface{} { return &SyslogAppenderFactory{} })
 }
 
@@ -47,10 +59,31 @@ func setLogLevel(name string, level gol.Level) {
 	logger, ok := gol.GetLogger(name).(*gol.DefaultLogger)
 	if ok {
 		logger.SetLevel(level)
+		addLoggerName(name)
 	}
 }
 
-// AppenderConfiguration is an union of console, file and syslog configuration.
+// addLoggerName records the name of a known logger.
+func addLoggerName(name string) {
+	loggerNames.Lock()
+	loggerNames.names[name] = struct{}{}
+	loggerNames.Unlock()
+}
+
+// getLoggerNames returns sorted names of all known loggers.
+func getLoggerNames() []string {
+	loggerNames.Lock()
+	names := make([]string, 0, len(loggerNames.names))
+	for name := range loggerNames.names {
+		names = append(names, name)
+	}
+	loggerNames.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// AppenderConfiguration is an union of console, file, rolling file and syslog
+// configuration.
 type AppenderConfiguration struct {
 	polytype.Type
 }
@@ -60,6 +93,8 @@ type Factory struct {
 	Level     string
 	Loggers   map[string]string
 	Appenders []AppenderConfiguration
+	// Watch reloads logger levels when a file is modified.
+	Watch WatchConfiguration
 }
 
 // Factory implements core.LoggingFactory interface.
@@ -76,28 +111,47 @@ func (factory *Factory) Configure(env *core.Environment) error {
 		gol.GetLogger(loggerName).Error("%v", err)
 		return err
 	}
+	if err = factory.configureWatch(env); err != nil {
+		gol.GetLogger(loggerName).Error("%v", err)
+		return err
+	}
 	env.Admin.AddTask(&logTask{})
 	return nil
 }
 
+// configureLevels sets the default level of the root logger and levels of
+// other loggers. No level is changed if any of them is unsupported.
 func (factory *Factory) configureLevels() error {
+	levels, err := parseLevels(factory.Level, factory.Loggers)
+	if err != nil {
+		return err
+	}
+	for k, v := range levels {
+		setLogLevel(k, v)
+	}
+	return nil
+}
+
+// parseLevels returns levels of the root logger and other loggers.
+func parseLevels(level string, loggers map[string]string) (map[string]gol.Level, error) {
+	levels := make(map[string]gol.Level, len(loggers)+1)
 	// Change default log level
-	if factory.Level != "" {
-		logLevel, ok := getLogLevel(factory.Level)
+	if level != "" {
+		logLevel, ok := getLogLevel(level)
 		if !ok {
-			return fmt.Errorf("logging: unsupported level %s", factory.Level)
+			return nil, fmt.Errorf("logging: unsupported level %s", level)
 		}
-		setLogLevel(gol.RootLoggerName, logLevel)
+		levels[gol.RootLoggerName] = logLevel
 	}
 	// Change level of other loggers
-	for k, v := range factory.Loggers {
+	for k, v := range loggers {
 		logLevel, ok := getLogLevel(v)
 		if !ok {
-			return fmt.Errorf("logging: unsupported level %s", v)
+			return nil, fmt.Errorf("logging: unsupported level %s for logger %s", v, k)
 		}
-		setLogLevel(k, logLevel)
+		levels[k] = logLevel
 	}
-	return nil
+	return levels, nil
 }
 
 func (factory *Factory) configureAppenders(environment *core.Environment) error {
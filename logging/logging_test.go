@@ -1,9 +1,13 @@
 package logging
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
 )
 
 func TestGetLogLevel(t *testing.T) {
@@ -36,3 +40,90 @@ func TestGetLogLevel(t *testing.T) {
 		t.Fatal("Should not found")
 	}
 }
+
+func TestLogTaskListLoggers(t *testing.T) {
+	setLogLevel("gomelon/logging/test", gol.LevelWarn)
+
+	task := &logTask{}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/log", nil)
+	task.ServeHTTP(w, r)
+
+	body := w.Body.String()
+	if !strings.Contains(body, gol.RootLoggerName+": ") {
+		t.Fatalf("missing root logger: %s", body)
+	}
+	if !strings.Contains(body, "gomelon/logging/test: WARN\n") {
+		t.Fatalf("missing configured logger: %s", body)
+	}
+}
+
+// testServerHandler records registered handlers.
+type testServerHandler map[string]http.Handler
+
+func (h testServerHandler) Handle(method, pattern string, handler interface{}) {
+	h[method+" "+pattern] = handler.(http.Handler)
+}
+
+func (h testServerHandler) PathPrefix() string {
+	return ""
+}
+
+func TestLogTaskListed(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &Factory{}
+	if err := factory.Configure(env); err != nil {
+		t.Fatal(err)
+	}
+	handlers := make(testServerHandler)
+	env.Admin.ServerHandler = handlers
+	if err := env.SetStarting(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.SetStopped()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/tasks", nil)
+	r.Header.Set("Accept", "text/plain")
+	handlers["GET /tasks"].ServeHTTP(w, r)
+	body := w.Body.String()
+	for _, s := range []string{"POST    /tasks/gc ", "POST    /tasks/log (*logging.logTask)"} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("missing %s: %s", s, body)
+		}
+	}
+}
+
+func TestConfigureLevels(t *testing.T) {
+	defer setLogLevel(gol.RootLoggerName, gol.LevelInfo)
+
+	factory := &Factory{
+		Level: "WARN",
+		Loggers: map[string]string{
+			"gomelon/logging/test/debug": "DEBUG",
+		},
+	}
+	if err := factory.configureLevels(); err != nil {
+		t.Fatal(err)
+	}
+	logger := gol.GetLogger("gomelon/logging/test/levels")
+	if logger.InfoEnabled() || !logger.WarnEnabled() {
+		t.Fatal("unexpected INFO enabled with root level WARN")
+	}
+	if !gol.GetLogger("gomelon/logging/test/debug").DebugEnabled() {
+		t.Fatal("DEBUG is not enabled for logger override")
+	}
+
+	factory = &Factory{
+		Level: "ERROR",
+		Loggers: map[string]string{
+			"gomelon/logging/test/invalid": "VERBOSE",
+		},
+	}
+	if err := factory.configureLevels(); err == nil {
+		t.Fatal("error expected")
+	}
+	if !logger.WarnEnabled() {
+		t.Fatal("root level must not be changed when a level is invalid")
+	}
+}
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
+)
+
+// backupTimeFormat is appended to the file name of rolled files so that they
+// are sorted by creation time.
+const backupTimeFormat = "20060102-150405.000000000"
+
+// RollingFileAppenderFactory provides an appender that writes logging events
+// to file system and rolls the file when its size exceeds MaxFileSize.
+type RollingFileAppenderFactory struct {
+	filteredAppenderFactory
+	formatFactory
+
+	CurrentLogFilename string `valid:"nonzero"`
+	// MaxFileSize is the maximum size in bytes of the current log file.
+	MaxFileSize int64
+	// ArchivedFileCount is the number of rolled files to keep. Zero keeps
+	// all of them.
+	ArchivedFileCount int
+	// MaxArchivedFileAge is the duration rolled files are kept, e.g. "168h".
+	// Files are kept regardless of their age if it is not set.
+	MaxArchivedFileAge string
+}
+
+func (factory *RollingFileAppenderFactory) Build(environment *core.Environment) (gol.Appender, error) {
+	if factory.MaxFileSize <= 0 {
+		return nil, fmt.Errorf("logging: invalid maxFileSize %d", factory.MaxFileSize)
+	}
+	if factory.ArchivedFileCount < 0 {
+		return nil, fmt.Errorf("logging: invalid archivedFileCount %d", factory.ArchivedFileCount)
+	}
+	var maxAge time.Duration
+	if factory.MaxArchivedFileAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(factory.MaxArchivedFileAge); err != nil {
+			return nil, fmt.Errorf("logging: invalid maxArchivedFileAge %v", err)
+		}
+	}
+	file := newRollingFile(factory.CurrentLogFilename, factory.MaxFileSize)
+	file.maxBackups = factory.ArchivedFileCount
+	file.maxAge = maxAge
+
+	formatted, err := factory.formatFactory.newAppender(file)
+	if err != nil {
+		return nil, err
+	}
+	appender, err := factory.filteredAppenderFactory.Build(formatted)
+	if err != nil {
+		return nil, err
+	}
+	// Open the file early so that configuration problems are reported
+	// at startup. Its Start method can be called multiple times.
+	if err := file.Start(); err != nil {
+		return nil, err
+	}
+	environment.Lifecycle.Manage(file)
+	return appender, nil
+}
+
+// rollingFile is a writer which renames the current file with a timestamp
+// suffix when it would exceed maxSize and removes old backups. Writes after
+// Stop go to stderr. It is safe for concurrent use.
+type rollingFile struct {
+	name    string
+	maxSize int64
+	// maxBackups and maxAge are not applied if they are zero.
+	maxBackups int
+	maxAge     time.Duration
+	// For testing
+	stderr io.Writer
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	stopped bool
+}
+
+func newRollingFile(name string, maxSize int64) *rollingFile {
+	return &rollingFile{
+		name:    name,
+		maxSize: maxSize,
+		stderr:  os.Stderr,
+	}
+}
+
+// Start opens the current file if it has not been opened.
+func (f *rollingFile) Start() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = false
+	if f.file != nil {
+		return nil
+	}
+	return f.open()
+}
+
+// Stop closes the current file.
+func (f *rollingFile) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rollingFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		return f.stderr.Write(b)
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && f.size+int64(len(b)) > f.maxSize {
+		if err := f.roll(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rollingFile) open() error {
+	file, err := os.OpenFile(f.name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = fi.Size()
+	return nil
+}
+
+// roll renames the current file, opens a new one and prunes old backups.
+func (f *rollingFile) roll() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup := f.name + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(f.name, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune()
+	return nil
+}
+
+// prune removes backups exceeding maxBackups or older than maxAge.
+func (f *rollingFile) prune() {
+	if f.maxBackups <= 0 && f.maxAge <= 0 {
+		return
+	}
+	backups := f.backups()
+	if f.maxBackups > 0 && len(backups) > f.maxBackups {
+		for _, name := range backups[:len(backups)-f.maxBackups] {
+			os.Remove(name)
+		}
+		backups = backups[len(backups)-f.maxBackups:]
+	}
+	if f.maxAge > 0 {
+		cutoff := time.Now().Add(-f.maxAge)
+		for _, name := range backups {
+			if fi, err := os.Stat(name); err == nil && fi.ModTime().Before(cutoff) {
+				os.Remove(name)
+			}
+		}
+	}
+}
+
+// backups returns backup file names, the oldest first.
+func (f *rollingFile) backups() []string {
+	matches, err := filepath.Glob(f.name + ".*")
+	if err != nil {
+		return nil
+	}
+	backups := matches[:0]
+	prefix := f.name + "."
+	for _, name := range matches {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(name, prefix)); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+var _ AppenderFactory = (*RollingFileAppenderFactory)(nil)
+
+func TestRollingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "test.log")
+	f := newRollingFile(name, 100)
+	defer f.Stop()
+	line := bytes.Repeat([]byte("a"), 59)
+	line = append(line, '\n')
+	for i := 0; i < 2; i++ {
+		if _, err = f.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups := f.backups()
+	if len(backups) != 1 {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+	fi, err := os.Stat(backups[0])
+	if err != nil || fi.Size() != 60 {
+		t.Fatalf("unexpected backup %v %v", fi, err)
+	}
+	fi, err = os.Stat(name)
+	if err != nil || fi.Size() != 60 {
+		t.Fatalf("unexpected file %v %v", fi, err)
+	}
+
+	// Stopped file is not reopened.
+	var stderr bytes.Buffer
+	f.stderr = &stderr
+	if err = f.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("stopped\n")); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.String() != "stopped\n" || f.file != nil {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+	fi, err = os.Stat(name)
+	if err != nil || fi.Size() != 60 {
+		t.Fatalf("unexpected file %v %v", fi, err)
+	}
+}
+
+func TestRollingFileConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "test.log")
+	f := newRollingFile(name, 100)
+	f.maxBackups = 2
+	defer f.Stop()
+
+	line := []byte("0123456789\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				f.Write(line)
+			}
+		}()
+	}
+	wg.Wait()
+	backups := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+	for _, backup := range append(backups, name) {
+		b, err := ioutil.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > 100 || len(b)%len(line) != 0 {
+			t.Fatalf("unexpected content of %s: %q", backup, b)
+		}
+	}
+}
+
+func TestRollingFileLogging(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	environment := core.NewEnvironment()
+	factory := &RollingFileAppenderFactory{
+		CurrentLogFilename: filepath.Join(dir, "test.log"),
+		MaxFileSize:        1024,
+		ArchivedFileCount:  2,
+		MaxArchivedFileAge: "24h",
+	}
+	appender, err := factory.Build(environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer environment.SetStopped()
+	if appender == nil {
+		t.Fatalf("rolling file appender is not created %#v", factory)
+	}
+
+	factory.MaxFileSize = 0
+	if _, err = factory.Build(environment); err == nil {
+		t.Fatal("error must be thrown")
+	}
+	factory.MaxFileSize = 1024
+	factory.MaxArchivedFileAge = "1 day"
+	if _, err = factory.Build(environment); err == nil {
+		t.Fatal("error must be thrown")
+	}
+}
@@ -0,0 +1,193 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/gol"
+)
+
+const (
+	// syslogRetryInterval is the minimum duration between connection
+	// attempts.
+	syslogRetryInterval = 10 * time.Second
+	// syslogStartTimeout is the maximum duration Start waits for the
+	// connection.
+	syslogStartTimeout = 5 * time.Second
+)
+
+var (
+	facilities = map[string]syslog.Priority{
+		"KERN":     syslog.LOG_KERN,
+		"USER":     syslog.LOG_USER,
+		"MAIL":     syslog.LOG_MAIL,
+		"DAEMON":   syslog.LOG_DAEMON,
+		"AUTH":     syslog.LOG_AUTH,
+		"SYSLOG":   syslog.LOG_SYSLOG,
+		"LPR":      syslog.LOG_LPR,
+		"NEWS":     syslog.LOG_NEWS,
+		"UUCP":     syslog.LOG_UUCP,
+		"CRON":     syslog.LOG_CRON,
+		"AUTHPRIV": syslog.LOG_AUTHPRIV,
+		"FTP":      syslog.LOG_FTP,
+		"LOCAL0":   syslog.LOG_LOCAL0,
+		"LOCAL1":   syslog.LOG_LOCAL1,
+		"LOCAL2":   syslog.LOG_LOCAL2,
+		"LOCAL3":   syslog.LOG_LOCAL3,
+		"LOCAL4":   syslog.LOG_LOCAL4,
+		"LOCAL5":   syslog.LOG_LOCAL5,
+		"LOCAL6":   syslog.LOG_LOCAL6,
+		"LOCAL7":   syslog.LOG_LOCAL7,
+	}
+)
+
+// syslogAppender writes logging events to syslog with the severity of their
+// level. Events are written to the fallback appender when syslog is not
+// reachable and the connection is retried in background after retryInterval.
+type syslogAppender struct {
+	network  string
+	addr     string
+	facility syslog.Priority
+	tag      string
+
+	// For testing
+	retryInterval time.Duration
+	fallback      gol.Appender
+
+	mu       sync.Mutex
+	writer   *syslog.Writer
+	nextDial time.Time
+	// dialing is closed when the current connection attempt completes.
+	dialing chan struct{}
+	stopped bool
+	// formatter writes formatted events to buf.
+	buf       bytes.Buffer
+	formatter gol.Appender
+}
+
+func newSyslogAppender(network, addr, facility, tag string) (managedAppender, error) {
+	a := &syslogAppender{
+		network:       network,
+		addr:          addr,
+		facility:      syslog.LOG_USER,
+		tag:           tag,
+		retryInterval: syslogRetryInterval,
+		fallback:      gol.NewAppender(os.Stderr),
+	}
+	if facility != "" {
+		f, ok := facilities[strings.ToUpper(facility)]
+		if !ok {
+			return nil, fmt.Errorf("logging: unsupported facility %s", facility)
+		}
+		a.facility = f
+	}
+	a.formatter = gol.NewAppender(&a.buf)
+	return a, nil
+}
+
+// Start connects to syslog if it has not been connected. It waits for the
+// connection up to syslogStartTimeout. Failures are not returned as the
+// connection is retried when logging.
+func (a *syslogAppender) Start() error {
+	a.mu.Lock()
+	a.stopped = false
+	var dialing chan struct{}
+	if a.writer == nil {
+		dialing = a.dial()
+	}
+	a.mu.Unlock()
+	if dialing != nil {
+		select {
+		case <-dialing:
+		case <-time.After(syslogStartTimeout):
+		}
+	}
+	return nil
+}
+
+// Stop closes the connection to syslog.
+func (a *syslogAppender) Stop() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopped = true
+	if a.writer == nil {
+		return nil
+	}
+	err := a.writer.Close()
+	a.writer = nil
+	return err
+}
+
+func (a *syslogAppender) Append(event *gol.LoggingEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.writer == nil {
+		a.dial()
+	}
+	if a.writer != nil {
+		a.buf.Reset()
+		a.formatter.Append(event)
+		err := a.write(event.Level, strings.TrimSuffix(a.buf.String(), "\n"))
+		if err == nil {
+			return
+		}
+		a.writer.Close()
+		a.writer = nil
+		a.nextDial = time.Now().Add(a.retryInterval)
+	}
+	a.fallback.Append(event)
+}
+
+// dial connects to syslog in background if no attempt is in progress and the
+// retry interval has passed. It returns a channel which is closed when the
+// attempt completes, or nil if it is not started. a.mu must be held.
+func (a *syslogAppender) dial() chan struct{} {
+	if a.dialing != nil {
+		return a.dialing
+	}
+	if time.Now().Before(a.nextDial) {
+		return nil
+	}
+	dialing := make(chan struct{})
+	a.dialing = dialing
+	go func() {
+		defer close(dialing)
+		writer, err := syslog.Dial(a.network, a.addr, a.facility, a.tag)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.dialing = nil
+		if err != nil {
+			a.nextDial = time.Now().Add(a.retryInterval)
+			return
+		}
+		if a.stopped || a.writer != nil {
+			writer.Close()
+			return
+		}
+		a.writer = writer
+	}()
+	return dialing
+}
+
+// write sends the message with the syslog severity of the level.
+func (a *syslogAppender) write(level gol.Level, msg string) error {
+	switch {
+	case level >= gol.LevelError:
+		return a.writer.Err(msg)
+	case level >= gol.LevelWarn:
+		return a.writer.Warning(msg)
+	case level >= gol.LevelInfo:
+		return a.writer.Info(msg)
+	default:
+		return a.writer.Debug(msg)
+	}
+}
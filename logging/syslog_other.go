@@ -0,0 +1,12 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logging
+
+import (
+	"errors"
+)
+
+func newSyslogAppender(network, addr, facility, tag string) (managedAppender, error) {
+	return nil, errors.New("logging: syslog is not supported on this platform")
+}
@@ -0,0 +1,133 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
+)
+
+func TestSyslogAppender(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	environment := core.NewEnvironment()
+	factory := &SyslogAppenderFactory{
+		Network:  "udp",
+		Addr:     conn.LocalAddr().String(),
+		Facility: "local0",
+		Tag:      "gomelon-test",
+	}
+	appender, err := factory.Build(environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer environment.SetStopped()
+
+	logger := gol.GetLogger("gomelon/logging/test/syslog").(*gol.DefaultLogger)
+	logger.SetAppender(appender)
+	defer logger.SetAppender(nil)
+	logger.Warn("hello %s", "syslog")
+
+	b := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(b[:n])
+	// LOG_LOCAL0 | LOG_WARNING
+	if !strings.HasPrefix(msg, "<132>") || !strings.Contains(msg, "gomelon-test") ||
+		!strings.Contains(msg, "hello syslog") {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func TestSyslogAppenderFallback(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	a, err := newSyslogAppender("tcp", addr, "", "gomelon-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa := a.(*syslogAppender)
+	var fallback bytes.Buffer
+	sa.fallback = gol.NewAppender(&fallback)
+	if err = sa.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sa.Stop()
+
+	logger := gol.GetLogger("gomelon/logging/test/syslogfallback").(*gol.DefaultLogger)
+	logger.SetAppender(sa)
+	defer logger.SetAppender(nil)
+	logger.Error("not reachable")
+	if !strings.Contains(fallback.String(), "not reachable") {
+		t.Fatalf("unexpected fallback output %q", fallback.String())
+	}
+
+	// Reconnect once syslog is available.
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	sa.mu.Lock()
+	sa.nextDial = time.Time{}
+	dialing := sa.dial()
+	sa.mu.Unlock()
+	// Connection is made in background.
+	<-dialing
+	logger.Error("reconnected")
+
+	l.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.Contains(line, "reconnected") {
+		t.Fatalf("unexpected message %q %v", line, err)
+	}
+	if strings.Contains(fallback.String(), "reconnected") {
+		t.Fatalf("unexpected fallback output %q", fallback.String())
+	}
+}
+
+func TestSyslogAppenderDialing(t *testing.T) {
+	a, err := newSyslogAppender("tcp", "127.0.0.1:1", "", "gomelon-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa := a.(*syslogAppender)
+	var fallback bytes.Buffer
+	sa.fallback = gol.NewAppender(&fallback)
+	// Connection attempt is in progress so events are not blocked.
+	sa.dialing = make(chan struct{})
+
+	logger := gol.GetLogger("gomelon/logging/test/syslogdialing").(*gol.DefaultLogger)
+	logger.SetAppender(sa)
+	defer logger.SetAppender(nil)
+	logger.Error("dialing")
+	if !strings.Contains(fallback.String(), "dialing") {
+		t.Fatalf("unexpected fallback output %q", fallback.String())
+	}
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/goburrow/gol"
@@ -11,7 +12,8 @@ const (
 	logTaskName = "log"
 )
 
-// logTask gets and sets logger level
+// logTask gets and sets logger level. All known loggers are listed if no
+// logger is specified.
 type logTask struct {
 }
 
@@ -24,6 +26,7 @@ func (*logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Can have multiple loggers
 	loggers, ok := query["logger"]
 	if !ok || len(loggers) == 0 {
+		printLogLevels(w, getLoggerNames())
 		return
 	}
 	// But only one level
@@ -38,8 +41,12 @@ func (*logTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			setLogLevel(name, logLevel)
 		}
 	}
-	// Print level of each logger
-	for _, name := range loggers {
+	printLogLevels(w, loggers)
+}
+
+// printLogLevels prints level of each logger.
+func printLogLevels(w io.Writer, names []string) {
+	for _, name := range names {
 		logger, ok := gol.GetLogger(name).(*gol.DefaultLogger)
 		if ok {
 			fmt.Fprintf(w, "%s: %s\n", name, gol.LevelString(logger.Level()))
@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/configuration"
+	"github.com/goburrow/gomelon/core"
+)
+
+const defaultWatchInterval = 5 * time.Second
+
+// WatchConfiguration contains settings of reloading logger levels.
+type WatchConfiguration struct {
+	// File is a JSON or YAML file, usually the configuration file, whose
+	// logging level and loggers are applied when it is modified.
+	File string
+	// Interval is the duration between checks of the file, default is "5s".
+	// Changes are applied once the file has not been modified for an interval.
+	Interval string
+}
+
+func (factory *Factory) configureWatch(environment *core.Environment) error {
+	if factory.Watch.File == "" {
+		return nil
+	}
+	interval := defaultWatchInterval
+	if factory.Watch.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(factory.Watch.Interval); err != nil {
+			return fmt.Errorf("logging: invalid watch interval %v", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("logging: invalid watch interval %v", interval)
+		}
+	}
+	environment.Lifecycle.Manage(newLevelWatcher(factory.Watch.File, interval))
+	return nil
+}
+
+// levelWatcher polls modification of a configuration file and applies its
+// logger levels.
+type levelWatcher struct {
+	file     string
+	interval time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLevelWatcher(file string, interval time.Duration) *levelWatcher {
+	return &levelWatcher{
+		file:     file,
+		interval: interval,
+	}
+}
+
+// Start starts watching the file.
+func (w *levelWatcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return nil
+	}
+	applied, _ := w.stat()
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(applied, w.stop, w.done)
+	return nil
+}
+
+// Stop stops watching the file.
+func (w *levelWatcher) Stop() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop == nil {
+		return nil
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+	w.done = nil
+	return nil
+}
+
+// fileState identifies a version of the file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func (w *levelWatcher) stat() (fileState, error) {
+	fi, err := os.Stat(w.file)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{fi.ModTime(), fi.Size()}, nil
+}
+
+// run checks the file every interval. A change is applied when the state of
+// the file is the same as the previous check so that levels are not applied
+// while the file is being written.
+func (w *levelWatcher) run(applied fileState, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	previous := applied
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		current, err := w.stat()
+		if err != nil {
+			continue
+		}
+		if current != applied && current == previous {
+			if err = w.apply(); err != nil {
+				gol.GetLogger(loggerName).Warn("could not reload levels from %s: %v", w.file, err)
+			}
+			applied = current
+		}
+		previous = current
+	}
+}
+
+// apply sets levels of loggers in the file.
+func (w *levelWatcher) apply() error {
+	var c struct {
+		Logging struct {
+			Level   string
+			Loggers map[string]string
+		}
+	}
+	if err := configuration.Unmarshal(w.file, &c); err != nil {
+		return err
+	}
+	levels, err := parseLevels(c.Logging.Level, c.Logging.Loggers)
+	if err != nil {
+		return err
+	}
+	logger := gol.GetLogger(loggerName)
+	for name, level := range levels {
+		l, ok := gol.GetLogger(name).(*gol.DefaultLogger)
+		if !ok {
+			continue
+		}
+		changed := l.Level() != level
+		setLogLevel(name, level)
+		if changed {
+			logger.Info("level of logger %s is changed to %s", name, gol.LevelString(level))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
+)
+
+func TestLevelWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "config.yaml")
+	content := "logging:\n  loggers:\n    gomelon/logging/test/watch: DEBUG\n"
+	if err = ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setLogLevel("gomelon/logging/test/watch", gol.LevelDebug)
+
+	w := newLevelWatcher(name, 10*time.Millisecond)
+	if err = w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	content = "logging:\n  loggers:\n    gomelon/logging/test/watch: ERROR\n"
+	if err = ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure modification time is changed.
+	modTime := time.Now().Add(time.Second)
+	if err = os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	logger := gol.GetLogger("gomelon/logging/test/watch").(*gol.DefaultLogger)
+	for i := 0; i < 200 && logger.Level() != gol.LevelError; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logger.Level() != gol.LevelError {
+		t.Fatalf("unexpected level %v", gol.LevelString(logger.Level()))
+	}
+	if err = w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigureWatch(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &Factory{
+		Watch: WatchConfiguration{
+			File:     "config.yaml",
+			Interval: "1 second",
+		},
+	}
+	if err := factory.configureWatch(env); err == nil {
+		t.Fatal("error expected")
+	}
+	factory.Watch.Interval = "1s"
+	if err := factory.configureWatch(env); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
+)
+
+const (
+	graphiteLoggerName = "gomelon/metrics/graphite"
+
+	graphiteDialTimeout  = 10 * time.Second
+	graphiteWriteTimeout = 10 * time.Second
+	graphiteMinBackoff   = time.Second
+	graphiteMaxBackoff   = 2 * time.Minute
+)
+
+// GraphiteFactory is the configuration of Graphite reporter.
+type GraphiteFactory struct {
+	// Addr is host:port of the Graphite server. The reporter is disabled if
+	// it is empty.
+	Addr string
+	// Prefix is prepended to metric names, e.g. "myapp.host1".
+	Prefix string
+}
+
+// GraphiteReporter periodically sends all counters and gauges to a Graphite
+// server using the plaintext protocol.
+type GraphiteReporter struct {
+	addr     string
+	prefix   string
+	interval time.Duration
+	logger   gol.Logger
+	// For testing
+	now func() time.Time
+
+	mu        sync.Mutex
+	conn      net.Conn
+	backoff   time.Duration
+	nextDial  time.Time
+	stop      chan struct{}
+	done      chan struct{}
+	isStarted bool
+}
+
+var _ core.Managed = (*GraphiteReporter)(nil)
+
+// NewGraphiteReporter allocates and returns a new GraphiteReporter sending
+// metrics to addr every interval.
+func NewGraphiteReporter(addr, prefix string, interval time.Duration) *GraphiteReporter {
+	return &GraphiteReporter{
+		addr:     addr,
+		prefix:   prefix,
+		interval: interval,
+		logger:   gol.GetLogger(graphiteLoggerName),
+		now:      time.Now,
+	}
+}
+
+// Start starts reporting in background.
+func (r *GraphiteReporter) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.isStarted {
+		return nil
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	r.isStarted = true
+	go r.run(r.stop, r.done)
+	return nil
+}
+
+// Stop sends the last report and closes the connection.
+func (r *GraphiteReporter) Stop() error {
+	r.mu.Lock()
+	if !r.isStarted {
+		r.mu.Unlock()
+		return nil
+	}
+	r.isStarted = false
+	close(r.stop)
+	done := r.done
+	r.mu.Unlock()
+	<-done
+
+	r.Report()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil {
+		err := r.conn.Close()
+		r.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (r *GraphiteReporter) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Report()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Report sends current metrics to the Graphite server. Connection is
+// re-established on failure, with exponential backoff.
+func (r *GraphiteReporter) Report() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if r.conn == nil {
+		if now.Before(r.nextDial) {
+			return
+		}
+		conn, err := net.DialTimeout("tcp", r.addr, graphiteDialTimeout)
+		if err != nil {
+			r.fail(now, err)
+			return
+		}
+		r.conn = conn
+		r.backoff = 0
+	}
+	r.conn.SetWriteDeadline(time.Now().Add(graphiteWriteTimeout))
+	if err := r.write(r.conn, now); err != nil {
+		r.conn.Close()
+		r.conn = nil
+		r.fail(now, err)
+	}
+}
+
+// fail logs the error and delays the next connection attempt.
+func (r *GraphiteReporter) fail(now time.Time, err error) {
+	if r.backoff == 0 {
+		r.backoff = graphiteMinBackoff
+	} else if r.backoff *= 2; r.backoff > graphiteMaxBackoff {
+		r.backoff = graphiteMaxBackoff
+	}
+	r.nextDial = now.Add(r.backoff)
+	r.logger.Error("could not report metrics to %s (retry in %v): %v", r.addr, r.backoff, err)
+}
+
+func (r *GraphiteReporter) write(conn net.Conn, now time.Time) error {
+	w := bufio.NewWriter(conn)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	counters, gauges := metrics.Snapshot()
+	values := make(map[string]string, len(counters)+len(gauges))
+	for name, v := range counters {
+		values[name] = strconv.FormatUint(v, 10)
+	}
+	for name, v := range gauges {
+		values[name] = strconv.FormatInt(v, 10)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %s %s\n", r.graphiteName(name), values[name], timestamp)
+	}
+	return w.Flush()
+}
+
+// graphiteName returns prefixed name which only contains characters
+// allowed in Graphite paths.
+func (r *GraphiteReporter) graphiteName(name string) string {
+	if r.prefix != "" {
+		name = r.prefix + "." + name
+	}
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '.' || c == '_' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
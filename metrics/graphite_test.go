@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+)
+
+func TestGraphiteReporter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	lines := make(chan string, 100)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	metrics.Counter("Test.Graphite Counter").AddN(4)
+	defer metrics.Counter("Test.Graphite Counter").Remove()
+	reporter := NewGraphiteReporter(l.Addr().String(), "app", 10*time.Millisecond)
+	reporter.now = func() time.Time { return time.Unix(1420000000, 0) }
+	if err = reporter.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer reporter.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if line == "app.Test.Graphite_Counter 4 1420000000" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("metric is not reported")
+		}
+	}
+}
+
+func TestGraphiteReporterReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	current := time.Unix(1420000000, 0)
+	reporter := NewGraphiteReporter(addr, "", time.Minute)
+	reporter.now = func() time.Time { return current }
+	reporter.Report()
+	if reporter.conn != nil || reporter.backoff != graphiteMinBackoff {
+		t.Fatalf("unexpected state %v %v", reporter.conn, reporter.backoff)
+	}
+	// Backoff
+	reporter.Report()
+	if reporter.backoff != graphiteMinBackoff {
+		t.Fatalf("unexpected backoff %v", reporter.backoff)
+	}
+	current = current.Add(graphiteMinBackoff)
+	reporter.Report()
+	if reporter.backoff != 2*graphiteMinBackoff {
+		t.Fatalf("unexpected backoff %v", reporter.backoff)
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			ioutil.ReadAll(conn)
+			conn.Close()
+		}
+	}()
+	current = current.Add(2 * graphiteMinBackoff)
+	reporter.Report()
+	if reporter.conn == nil || reporter.backoff != 0 {
+		t.Fatalf("unexpected state %v %v", reporter.conn, reporter.backoff)
+	}
+	reporter.conn.Close()
+}
@@ -4,10 +4,15 @@ Package metrics provides metrics configuration for applications.
 package metrics
 
 import (
+	"bytes"
+	"encoding/json"
 	"expvar"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
-	_ "github.com/codahale/metrics"
+	"github.com/codahale/metrics"
 	_ "github.com/codahale/metrics/runtime"
 	"github.com/goburrow/gomelon/core"
 )
@@ -15,10 +20,16 @@ import (
 const (
 	metricsUri = "/metrics"
 	metricsVar = "metrics"
+
+	defaultFrequency = "1m"
 )
 
-// metricsHandler displays expvars.
+// metricsHandler displays counters and gauges. Histograms are included as
+// gauges of their percentiles. Prometheus text format is responded when it
+// is requested in Accept header, or OpenMetrics text format if exemplars are
+// enabled. Bucket histograms are only included in text formats.
 type metricsHandler struct {
+	env *core.AdminEnvironment
 }
 
 var _ core.AdminHandler = (*metricsHandler)(nil)
@@ -31,28 +42,118 @@ func (handler *metricsHandler) Path() string {
 	return metricsUri
 }
 
-func (*metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (handler *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
 
-	val := expvar.Get(metricsVar)
-	if val == nil {
+	if expvar.Get(metricsVar) == nil {
 		w.WriteHeader(http.StatusNotImplemented)
 		w.Write([]byte("No metrics."))
 		return
 	}
+	counters, gauges := metrics.Snapshot()
+	if core.ExemplarsEnabled() && acceptsOpenMetrics(r) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		writeOpenMetrics(w, counters, gauges, core.Histograms(), core.Exemplars())
+		return
+	}
+	if acceptsPrometheus(r) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		writePrometheus(w, counters, gauges, core.Histograms())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(handler.env, w, r, &snapshot{counters, gauges})
+}
+
+// metricHandler displays or resets a single metric.
+type metricHandler struct {
+	env *core.AdminEnvironment
+}
+
+// ServeHTTP responds value of the metric in the request path. POST method
+// resets the counter.
+func (handler *metricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "must-revalidate,no-cache,no-store")
+
+	name := strings.TrimPrefix(r.URL.Path, metricsUri+"/")
+	counters, gauges := metrics.Snapshot()
+	var value interface{}
+	if v, ok := counters[name]; ok {
+		if r.Method == "POST" {
+			metrics.Counter(name).Remove()
+		}
+		value = v
+	} else if v, ok := gauges[name]; ok {
+		if r.Method == "POST" {
+			http.Error(w, fmt.Sprintf("Gauge %s can not be reset.", name), http.StatusBadRequest)
+			return
+		}
+		value = v
+	} else {
+		http.NotFound(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(val.String()))
+	encodeJSON(handler.env, w, r, map[string]interface{}{name: value})
+}
+
+// encodeJSON writes JSON encoding of v to w. The output is indented if
+// the request has pretty=true query parameter.
+func encodeJSON(env *core.AdminEnvironment, w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if r.URL.Query().Get("pretty") != "true" {
+		return env.JSONEncoder.Encode(w, v)
+	}
+	var buf, out bytes.Buffer
+	if err := env.JSONEncoder.Encode(&buf, v); err != nil {
+		return err
+	}
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// snapshot has the same JSON format as the metrics expvar.
+type snapshot struct {
+	Counters map[string]uint64
+	Gauges   map[string]int64
 }
 
 type Factory struct {
+	// Frequency is the interval of reporting metrics, default is 1m.
 	Frequency string
+	// Graphite reports metrics to a Graphite server if it is configured.
+	Graphite GraphiteFactory
+	// Internal enables metrics of the framework itself, e.g. number of
+	// health checks run and tasks invoked.
+	Internal bool
+	// Exemplars records route latencies in bucket histograms and attaches
+	// trace IDs of requests with W3C traceparent header to their buckets in
+	// OpenMetrics format.
+	Exemplars bool
 }
 
 // Factory implements core.MetricsFactory interface.
 var _ core.MetricsFactory = (*Factory)(nil)
 
 func (factory *Factory) Configure(env *core.Environment) error {
-	env.Admin.AddHandler(&metricsHandler{})
-	// TODO: configure frequency in metrics.
+	env.Admin.AddHandler(&metricsHandler{env.Admin})
+	metricHandler := &metricHandler{env.Admin}
+	env.Admin.AddEndpoint("GET", metricsUri+"/:name", metricHandler)
+	env.Admin.AddEndpoint("POST", metricsUri+"/:name", metricHandler)
+	core.EnableInternalMetrics(factory.Internal)
+	core.EnableExemplars(factory.Exemplars)
+	if factory.Graphite.Addr != "" {
+		frequency := factory.Frequency
+		if frequency == "" {
+			frequency = defaultFrequency
+		}
+		interval, err := time.ParseDuration(frequency)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("metrics: invalid frequency %s", frequency)
+		}
+		env.Lifecycle.Manage(NewGraphiteReporter(factory.Graphite.Addr, factory.Graphite.Prefix, interval))
+	}
 	return nil
 }
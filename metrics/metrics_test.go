@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/gomelon/core"
+)
+
+func TestMetricHandler(t *testing.T) {
+	metrics.Counter("Test.Counter").AddN(3)
+	env := core.NewEnvironment()
+	handler := &metricHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics/Test.Counter", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if strings.TrimSpace(w.Body.String()) != `{"Test.Counter":3}` {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+	// Reset
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/metrics/Test.Counter", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	counters, _ := metrics.Snapshot()
+	if counters["Test.Counter"] != 0 {
+		t.Fatalf("counter is not reset %v", counters["Test.Counter"])
+	}
+}
+
+func TestMetricHandlerNotFound(t *testing.T) {
+	env := core.NewEnvironment()
+	handler := &metricHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics/Test.Unknown", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestMetricsHandlerPretty(t *testing.T) {
+	metrics.Counter("Test.Pretty").Add()
+	env := core.NewEnvironment()
+	handler := &metricsHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics?pretty=true", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "must-revalidate,no-cache,no-store" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "\n    \"Test.Pretty\": 1") {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
+
+func TestMetricsHandlerPrometheus(t *testing.T) {
+	metrics.Counter("Test.Prometheus.Requests").AddN(2)
+	defer metrics.Counter("Test.Prometheus.Requests").Remove()
+	metrics.Gauge("Test.Prometheus.Gauge").Set(5)
+	metrics.NewHistogram("Test.Prometheus.Latency", 1, 1000, 3)
+	env := core.NewEnvironment()
+	handler := &metricsHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept", "text/plain; version=0.0.4")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != prometheusContentType {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Header())
+	}
+	body := w.Body.String()
+	expected := []string{
+		"# HELP Test_Prometheus_Requests Test.Prometheus.Requests\n# TYPE Test_Prometheus_Requests counter\n",
+		"# TYPE Test_Prometheus_Gauge gauge\nTest_Prometheus_Gauge 5\n",
+		"# TYPE Test_Prometheus_Latency summary\nTest_Prometheus_Latency{quantile=\"0.5\"} ",
+	}
+	for _, s := range expected {
+		if !strings.Contains(body, s) {
+			t.Fatalf("expected %q in body:\n%v", s, body)
+		}
+	}
+	if strings.Contains(body, "Test_Prometheus_Latency_P50") {
+		t.Fatalf("unexpected percentile gauge:\n%v", body)
+	}
+	// Parse sample line
+	var value uint64
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "Test_Prometheus_Requests ") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				t.Fatalf("unexpected line %q", line)
+			}
+			var err error
+			if value, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if value != 2 {
+		t.Fatalf("unexpected counter value %v in:\n%v", value, body)
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	data := map[string]string{
+		"HTTP.Routes.GET /users/:id.Requests": "HTTP_Routes_GET__users__id_Requests",
+		"9lives":                              "_lives",
+		"valid_name":                          "valid_name",
+	}
+	for name, expected := range data {
+		if actual := prometheusName(name); actual != expected {
+			t.Fatalf("unexpected name %q, expected %q", actual, expected)
+		}
+	}
+}
+
+func TestMetricsHandlerApplication(t *testing.T) {
+	env := core.NewEnvironment()
+	env.Metrics.Counter("Test.Application.Counter").Add()
+	defer env.Metrics.Counter("Test.Application.Counter").Remove()
+	handler := &metricsHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	handler.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `"Test.Application.Counter":1`) {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	metrics.Counter("Test.OpenMetrics.Requests").AddN(2)
+	defer metrics.Counter("Test.OpenMetrics.Requests").Remove()
+	core.EnableExemplars(true)
+	defer core.EnableExemplars(false)
+	core.SetExemplar("Test.OpenMetrics.Requests", core.Exemplar{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		Value:   0.25,
+		Time:    time.Unix(1500000000, 0),
+	})
+	histogram := core.Histogram("Test.OpenMetrics.Latency", []float64{0.1, 1})
+	defer core.RemoveHistogram("Test.OpenMetrics.Latency")
+	histogram.Observe(0.05, nil)
+	histogram.Observe(0.5, &core.Exemplar{
+		TraceID: "0af7651916cd43dd8448eb211c80319c",
+		Value:   0.5,
+		Time:    time.Unix(1500000000, 0),
+	})
+	env := core.NewEnvironment()
+	handler := &metricsHandler{env.Admin}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;version=0.0.4;q=0.5")
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != openMetricsContentType {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Header())
+	}
+	body := w.Body.String()
+	expected := "# TYPE Test_OpenMetrics_Requests counter\n" +
+		"Test_OpenMetrics_Requests_total 2 # {trace_id=\"4bf92f3577b34da6a3ce929d0e0e4736\"} 0.25 1500000000.000\n"
+	if !strings.Contains(body, expected) || !strings.HasSuffix(body, "# EOF\n") {
+		t.Fatalf("unexpected body:\n%v", body)
+	}
+	expected = "# TYPE Test_OpenMetrics_Latency histogram\n" +
+		"Test_OpenMetrics_Latency_bucket{le=\"0.1\"} 1\n" +
+		"Test_OpenMetrics_Latency_bucket{le=\"1.0\"} 2 # {trace_id=\"0af7651916cd43dd8448eb211c80319c\"} 0.5 1500000000.000\n" +
+		"Test_OpenMetrics_Latency_bucket{le=\"+Inf\"} 2\n" +
+		"Test_OpenMetrics_Latency_count 2\n" +
+		"Test_OpenMetrics_Latency_sum 0.55\n"
+	if !strings.Contains(body, expected) {
+		t.Fatalf("unexpected body:\n%v", body)
+	}
+
+	// Prometheus format is used when exemplars are disabled.
+	core.EnableExemplars(false)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != prometheusContentType {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// quantiles are suffixes of gauges generated by histograms.
+var quantiles = []struct {
+	suffix   string
+	quantile string
+}{
+	{".P50", "0.5"},
+	{".P75", "0.75"},
+	{".P90", "0.9"},
+	{".P95", "0.95"},
+	{".P99", "0.99"},
+	{".P999", "0.999"},
+}
+
+// acceptsPrometheus returns true if the request prefers the Prometheus text
+// format to JSON, which is what Prometheus servers send when scraping.
+func acceptsPrometheus(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "application/json") {
+		return false
+	}
+	return strings.Contains(accept, "text/plain") ||
+		strings.Contains(accept, "application/openmetrics-text")
+}
+
+// acceptsOpenMetrics returns true if the request accepts OpenMetrics text
+// format, which is needed for exemplars.
+func acceptsOpenMetrics(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return !strings.Contains(accept, "application/json") &&
+		strings.Contains(accept, "application/openmetrics-text")
+}
+
+// writePrometheus writes counters, gauges and bucket histograms in Prometheus
+// text exposition format. Gauges of histogram percentiles are grouped as
+// summaries.
+func writePrometheus(w io.Writer, counters map[string]uint64, gauges map[string]int64,
+	histograms map[string]core.HistogramSnapshot) error {
+	return writeExposition(w, counters, gauges, histograms, nil, false)
+}
+
+// writeOpenMetrics writes counters, gauges and bucket histograms in
+// OpenMetrics text format with exemplars of counters and histogram buckets.
+func writeOpenMetrics(w io.Writer, counters map[string]uint64, gauges map[string]int64,
+	histograms map[string]core.HistogramSnapshot, exemplars map[string]core.Exemplar) error {
+	return writeExposition(w, counters, gauges, histograms, exemplars, true)
+}
+
+func writeExposition(w io.Writer, counters map[string]uint64, gauges map[string]int64,
+	histograms map[string]core.HistogramSnapshot, exemplars map[string]core.Exemplar, openMetrics bool) error {
+	bw := bufio.NewWriter(w)
+	// Sanitized names may collide.
+	written := make(map[string]bool)
+
+	for _, name := range sortedKeys(counters) {
+		promName := prometheusName(name)
+		if written[promName] {
+			continue
+		}
+		written[promName] = true
+		if !openMetrics {
+			writeHeader(bw, promName, name, "counter")
+			bw.WriteString(promName + " " + strconv.FormatUint(counters[name], 10) + "\n")
+			continue
+		}
+		// Samples of OpenMetrics counters have _total suffix.
+		family := strings.TrimSuffix(promName, "_total")
+		writeHeader(bw, family, name, "counter")
+		bw.WriteString(family + "_total " + strconv.FormatUint(counters[name], 10))
+		if exemplar, ok := exemplars[name]; ok {
+			writeExemplar(bw, &exemplar)
+		}
+		bw.WriteString("\n")
+	}
+	for _, name := range sortedKeys(gauges) {
+		typ := "gauge"
+		base, isSummary := histogramName(name, gauges)
+		if isSummary {
+			typ = "summary"
+			name = base
+		}
+		promName := prometheusName(name)
+		if written[promName] {
+			continue
+		}
+		written[promName] = true
+		writeHeader(bw, promName, name, typ)
+		if isSummary {
+			for _, q := range quantiles {
+				bw.WriteString(promName + `{quantile="` + q.quantile + `"} ` +
+					strconv.FormatInt(gauges[name+q.suffix], 10) + "\n")
+			}
+		} else {
+			bw.WriteString(promName + " " + strconv.FormatInt(gauges[name], 10) + "\n")
+		}
+	}
+	for _, name := range sortedKeys(histograms) {
+		promName := prometheusName(name)
+		if written[promName] {
+			continue
+		}
+		written[promName] = true
+		writeHeader(bw, promName, name, "histogram")
+		h := histograms[name]
+		for i, count := range h.Counts {
+			le := "+Inf"
+			if i < len(h.Bounds) {
+				le = formatBound(h.Bounds[i])
+			}
+			bw.WriteString(promName + `_bucket{le="` + le + `"} ` + strconv.FormatUint(count, 10))
+			if openMetrics && h.Exemplars[i] != nil {
+				writeExemplar(bw, h.Exemplars[i])
+			}
+			bw.WriteString("\n")
+		}
+		bw.WriteString(promName + "_count " + strconv.FormatUint(h.Count, 10) + "\n")
+		bw.WriteString(promName + "_sum " + strconv.FormatFloat(h.Sum, 'g', -1, 64) + "\n")
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+func writeExemplar(w *bufio.Writer, exemplar *core.Exemplar) {
+	w.WriteString(` # {trace_id="` + exemplar.TraceID + `"} ` +
+		strconv.FormatFloat(exemplar.Value, 'g', -1, 64) + " " +
+		strconv.FormatFloat(float64(exemplar.Time.UnixNano())/1e9, 'f', 3, 64))
+}
+
+// formatBound formats the upper bound of a histogram bucket in canonical
+// form, e.g. 1.0 rather than 1.
+func formatBound(bound float64) string {
+	s := strconv.FormatFloat(bound, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func writeHeader(w *bufio.Writer, promName, name, typ string) {
+	w.WriteString("# HELP " + promName + " " + escapeHelp(name) + "\n")
+	w.WriteString("# TYPE " + promName + " " + typ + "\n")
+}
+
+// histogramName returns name of the histogram if the gauge is one of its
+// percentiles and all the other percentiles exist.
+func histogramName(name string, gauges map[string]int64) (string, bool) {
+	for _, q := range quantiles {
+		if strings.HasSuffix(name, q.suffix) {
+			base := strings.TrimSuffix(name, q.suffix)
+			for _, p := range quantiles {
+				if _, ok := gauges[base+p.suffix]; !ok {
+					return "", false
+				}
+			}
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// prometheusName replaces characters which are not allowed in Prometheus
+// metric names with underscores.
+func prometheusName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func escapeHelp(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]uint64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]int64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]core.HistogramSnapshot:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
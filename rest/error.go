@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
 )
 
 var errorLogger gol.Logger
@@ -12,20 +13,13 @@ func init() {
 	errorLogger = gol.GetLogger("gomelon/rest/error")
 }
 
-type HTTPError struct {
-	Message string
-	Code    int
-}
+// HTTPError is an error with HTTP status, see core.HTTPError.
+type HTTPError = core.HTTPError
 
+// NewHTTPError allocates and returns a new HTTPError with the given message
+// and HTTP status code.
 func NewHTTPError(msg string, code int) *HTTPError {
-	return &HTTPError{
-		Message: msg,
-		Code:    code,
-	}
-}
-
-func (e *HTTPError) Error() string {
-	return e.Message
+	return core.NewHTTPError(code, msg)
 }
 
 // ErrorMapper maps error to http error.
@@ -42,12 +36,11 @@ func newErrorMapper() *defaultErrorMapper {
 }
 
 func (h *defaultErrorMapper) MapError(err error, w http.ResponseWriter, r *http.Request) {
-	errorLogger.Debug("%v: %#v", r.URL, err)
-	// TODO: log error
-	switch v := err.(type) {
-	case *HTTPError:
-		http.Error(w, v.Message, v.Code)
-	default:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, ok := err.(*HTTPError); ok {
+		errorLogger.Debug("%v: %#v", r.URL, err)
+	} else {
+		// Details of other errors are only logged.
+		errorLogger.Error("%v: %v", r.URL, err)
 	}
+	core.WriteError(w, r, err)
 }
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/gol"
+)
+
+func init() {
+	// Disable logger
+	errorLogger.(*gol.DefaultLogger).SetLevel(gol.LevelOff)
+}
+
+func TestErrorMapper(t *testing.T) {
+	mapper := newErrorMapper()
+	data := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{NewHTTPError("User not found.", http.StatusNotFound), http.StatusNotFound, "User not found.\n"},
+		{errors.New("sql: connection refused"), http.StatusInternalServerError, "Internal Server Error\n"},
+	}
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/1", nil)
+		mapper.MapError(d.err, w, r)
+		if w.Code != d.code || w.Body.String() != d.body {
+			t.Fatalf("unexpected response %v %q for %v", w.Code, w.Body.String(), d.err)
+		}
+	}
+}
@@ -55,7 +55,10 @@ func (command *ServerCommand) Run(bootstrap *core.Bootstrap) error {
 		logger.Error("could not run application: %v", err)
 		return err
 	}
-	command.Environment.SetStarting()
+	if err = command.Environment.SetStarting(); err != nil {
+		logger.Error("could not start environment: %v", err)
+		return err
+	}
 	defer command.Server.Stop()
 	if err = command.Server.Start(); err != nil {
 		logger.Error("could not start server: %v", err)
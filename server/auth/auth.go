@@ -0,0 +1,62 @@
+/*
+Package auth provides filters for HTTP authentication.
+*/
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+const (
+	filterName   = "auth"
+	defaultRealm = "gomelon"
+)
+
+// BasicFilter requires HTTP basic authentication with the given credentials.
+type BasicFilter struct {
+	// Realm is sent in WWW-Authenticate header.
+	Realm string
+
+	username []byte
+	password []byte
+}
+
+var _ filter.Filter = (*BasicFilter)(nil)
+
+// NewBasicFilter allocates and returns a new BasicFilter.
+func NewBasicFilter(username, password string) *BasicFilter {
+	return &BasicFilter{
+		Realm:    defaultRealm,
+		username: []byte(username),
+		password: []byte(password),
+	}
+}
+
+func (f *BasicFilter) Name() string {
+	return filterName
+}
+
+func (f *BasicFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	if !f.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", f.Realm))
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// authenticate checks credentials in constant time.
+func (f *BasicFilter) authenticate(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// Both are always compared.
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), f.username)
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), f.password)
+	return usernameOK&passwordOK == 1
+}
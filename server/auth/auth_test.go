@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+func TestBasicFilter(t *testing.T) {
+	builder := filter.NewChain()
+	builder.Add(NewBasicFilter("admin", "secret"))
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		username, password string
+		code               int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"admin", "wrong", http.StatusUnauthorized},
+		{"wrong", "secret", http.StatusUnauthorized},
+		{"admin", "secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		if test.username != "" {
+			r.SetBasicAuth(test.username, test.password)
+		}
+		chain.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Fatalf("unexpected code %v for %+v", w.Code, test)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != `Basic realm="gomelon"` {
+			t.Fatalf("unexpected headers %v", w.Header())
+		}
+	}
+}
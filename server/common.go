@@ -2,45 +2,329 @@ package server
 
 import (
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/goburrow/gomelon/core"
+	"github.com/goburrow/gomelon/server/auth"
 	"github.com/goburrow/gomelon/server/filter"
+	"github.com/goburrow/gomelon/server/proxy"
 	"github.com/goburrow/gomelon/server/recovery"
 	"github.com/goburrow/polytype"
+	"github.com/zenazn/goji/web"
+	"golang.org/x/net/context"
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
 // RequestLogConfiguration is the user defined type of RequestLogFactory.
+// Registered types are DefaultRequestLog, json and combined.
 type RequestLogConfiguration struct {
 	polytype.Type
 }
 
+// AdminConfiguration contains settings of admin handler.
+type AdminConfiguration struct {
+	// Username and Password enable HTTP basic authentication for all
+	// admin endpoints when they are set.
+	Username string
+	Password string `secret:"true"`
+}
+
 // commonFactory is the shared configuration of DefaultFactory and
 // SimpleFactory.
 type commonFactory struct {
 	RequestLog RequestLogConfiguration
+	Admin      AdminConfiguration
+	// MaxHandlers is the sanity limit of handlers registered in each server
+	// handler. It is disabled by default.
+	MaxHandlers int
+	// ShutdownTimeout is the grace period for draining connections when
+	// the server stops, after which they are closed. Default is "30s" and
+	// "0s" waits for all connections.
+	ShutdownTimeout string
+	// ResponseHeaders are default headers of all application responses.
+	ResponseHeaders map[string]string
+	// HealthCheckTimeout is the maximum duration of each health check, e.g.
+	// "5s". No timeout if it is empty.
+	HealthCheckTimeout string
+	// HealthCheckCacheTTL is the duration health check results are reused,
+	// e.g. "10s". Health checks are run on every request if it is empty.
+	HealthCheckCacheTTL string
+	// TaskTimeout is the maximum running duration of admin tasks, e.g.
+	// "1m". No timeout if it is empty.
+	TaskTimeout string
+	// TrustedProxies are addresses or CIDR blocks of reverse proxies whose
+	// X-Forwarded-For header is used as the client address and
+	// X-Forwarded-Proto and X-Forwarded-Host headers are used to build
+	// redirects and links. Forwarded headers are ignored if it is empty.
+	TrustedProxies []string
+	// RequestTimeout is the maximum duration of handling a request, e.g.
+	// "30s". 503 Service Unavailable is responded when it is exceeded.
+	// No timeout if it is empty.
+	RequestTimeout string
+	// Recovery configures the response and log levels of recovered panics.
+	Recovery recovery.Factory
+	// RequestID adds X-Request-Id header to requests and responses if it is
+	// not set by the client.
+	RequestID bool
+	// RateLimit configures request rate limiting of each client.
+	RateLimit filter.RateLimitFactory
+	// BodyLimit configures maximum size of request bodies.
+	BodyLimit filter.BodyLimitFactory
+	// BodyLog logs request and response bodies for debugging.
+	BodyLog filter.BodyLogFactory
+	// Gzip configures compression of responses.
+	Gzip filter.GzipFactory
+	// CORS configures cross-origin resource sharing.
+	CORS filter.CORSFactory
+	// ConnectorsHealthCheck registers a health check which verifies all
+	// connectors are accepting connections. It is enabled by default.
+	ConnectorsHealthCheck *bool
+	// DisableDefaultHealthCheck removes the liveness health check which is
+	// registered by default, so a warning is logged when the application has
+	// no health checks.
+	DisableDefaultHealthCheck bool
+}
+
+// validate returns all problems of the shared configuration.
+func (f *commonFactory) validate() []string {
+	var problems []string
+	problems = append(problems, validateDuration("shutdownTimeout", f.ShutdownTimeout)...)
+	problems = append(problems, validateDuration("healthCheckTimeout", f.HealthCheckTimeout)...)
+	problems = append(problems, validateDuration("healthCheckCacheTTL", f.HealthCheckCacheTTL)...)
+	problems = append(problems, validateDuration("taskTimeout", f.TaskTimeout)...)
+	problems = append(problems, validateDuration("requestTimeout", f.RequestTimeout)...)
+	return problems
+}
+
+// newServer creates a new Server with shutdown timeout.
+func (f *commonFactory) newServer(env *core.Environment) (*Server, error) {
+	server := NewServer()
+	server.ShutdownTimeout = defaultShutdownTimeout
+	server.PreShutdown = env.Lifecycle.RunPreShutdownHooks
+	if f.ShutdownTimeout != "" {
+		timeout, err := time.ParseDuration(f.ShutdownTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("server: invalid shutdown timeout %v", err)
+		}
+		server.ShutdownTimeout = timeout
+	}
+	return server, nil
+}
+
+// configureAdmin sets timeouts of health checks and tasks and registers
+// connectors health check if it is enabled. The default health check is
+// removed if it is disabled.
+func (f *commonFactory) configureAdmin(env *core.Environment, server *Server) error {
+	if f.HealthCheckTimeout != "" {
+		timeout, err := time.ParseDuration(f.HealthCheckTimeout)
+		if err != nil {
+			return fmt.Errorf("server: invalid health check timeout %v", err)
+		}
+		env.Admin.HealthCheckTimeout = timeout
+	}
+	if f.HealthCheckCacheTTL != "" {
+		ttl, err := time.ParseDuration(f.HealthCheckCacheTTL)
+		if err != nil {
+			return fmt.Errorf("server: invalid health check cache TTL %v", err)
+		}
+		env.Admin.HealthCheckCacheTTL = ttl
+	}
+	if f.TaskTimeout != "" {
+		timeout, err := time.ParseDuration(f.TaskTimeout)
+		if err != nil {
+			return fmt.Errorf("server: invalid task timeout %v", err)
+		}
+		env.Admin.TaskTimeout = timeout
+	}
+	if f.DisableDefaultHealthCheck {
+		env.Admin.HealthChecks.Unregister(core.DefaultHealthCheckName)
+	}
+	if len(server.Connectors) > 0 && (f.ConnectorsHealthCheck == nil || *f.ConnectorsHealthCheck) {
+		env.Admin.HealthChecks.Register(connectorsHealthCheckName,
+			&connectorsHealthCheck{connectors: server.Connectors})
+	}
+	return nil
+}
+
+// newHandler creates a new Handler with filter chain.
+func (f *commonFactory) newHandler() *Handler {
+	handler := NewHandler()
+	handler.MaxHandlers = f.MaxHandlers
+	handler.ServeMux.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			for k, v := range handler.Headers {
+				header[k] = append([]string(nil), v...)
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	handler.ServeMux.Use(func(h http.Handler) http.Handler {
+		return handler.FilterChain.Build(h)
+	})
+	// Route the request before dispatching so the matched pattern is
+	// available to the metrics filter and path parameters to handlers.
+	handler.ServeMux.Use(handler.ServeMux.Router)
+	handler.ServeMux.Use(func(c *web.C, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if match := web.GetMatch(*c); match.Pattern != nil {
+				filter.SetRoute(r.Context(), fmt.Sprintf("%s %s%v", routeMethod(r.Method), handler.pathPrefix, match.RawPattern()))
+			}
+			if len(c.URLParams) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), pathParamsKey, c.URLParams))
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
+	return handler
+}
+
+// routeMethods are request methods used in route metrics. Others are
+// reported as otherRouteMethod so that clients can not create arbitrary
+// metrics on handlers registered for all methods.
+var routeMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "OPTIONS": true, "CONNECT": true, "TRACE": true,
 }
 
-// AddFilters adds request log and panic recovery to the filter chain
-// of the given handlers.
+const otherRouteMethod = "OTHER"
+
+func routeMethod(method string) string {
+	if routeMethods[method] {
+		return method
+	}
+	return otherRouteMethod
+}
+
+// newAppHandler creates a new Handler with default response headers.
+func (f *commonFactory) newAppHandler() *Handler {
+	handler := f.newHandler()
+	if len(f.ResponseHeaders) > 0 {
+		handler.Headers = make(http.Header, len(f.ResponseHeaders))
+		for k, v := range f.ResponseHeaders {
+			handler.Headers.Set(k, v)
+		}
+	}
+	return handler
+}
+
+// wrapHandler applies the handler wrapper of the environment if provided.
+func (f *commonFactory) wrapHandler(env *core.Environment, handler http.Handler) http.Handler {
+	if env.Server.HandlerWrapper != nil {
+		return env.Server.HandlerWrapper(handler)
+	}
+	return handler
+}
+
+// AddFilters adds trusted proxies, request ID, route metrics, request log,
+// panic recovery, rate limit, body limit, gzip compression and CORS if enabled
+// to the filter chain of the given handlers.
 func (f *commonFactory) AddFilters(env *core.Environment, handlers ...*Handler) error {
-	requestLogFilter, err := f.getRequestLog(env)
+	if len(f.TrustedProxies) > 0 {
+		proxyFilter, err := proxy.NewFilter(f.TrustedProxies)
+		if err != nil {
+			return err
+		}
+		for _, h := range handlers {
+			h.FilterChain.Add(proxyFilter)
+		}
+	}
+	if f.RequestID {
+		requestIDFilter := filter.NewRequestIDFilter()
+		for _, h := range handlers {
+			h.FilterChain.Add(requestIDFilter)
+		}
+	}
+	metricsFilter := filter.NewMetricsFilter()
+	for _, h := range handlers {
+		h.FilterChain.Add(metricsFilter)
+	}
+	requestLog, err := f.getRequestLog(env)
+	if err != nil {
+		return err
+	}
+	requestLogFilter := &requestLogFilter{filter: requestLog}
+	env.Admin.AddTask(&requestLogTask{
+		factory: f,
+		env:     env,
+		filter:  requestLogFilter,
+	})
+	recoveryFilter, err := f.Recovery.Build()
 	if err != nil {
 		return err
 	}
-	recoveryFilter := recovery.NewFilter()
 	for _, h := range handlers {
 		h.FilterChain.Add(requestLogFilter)
 		h.FilterChain.Add(recoveryFilter)
 	}
+	if f.RateLimit.Enabled {
+		rateLimitFilter, err := f.RateLimit.Build()
+		if err != nil {
+			return err
+		}
+		for _, h := range handlers {
+			h.FilterChain.Add(rateLimitFilter)
+		}
+	}
+	if f.BodyLimit.Enabled {
+		bodyLimitFilter := f.BodyLimit.Build()
+		for _, h := range handlers {
+			h.FilterChain.Add(bodyLimitFilter)
+		}
+	}
+	if f.Gzip.Enabled {
+		gzipFilter := f.Gzip.Build()
+		for _, h := range handlers {
+			h.FilterChain.Add(gzipFilter)
+		}
+	}
+	if f.CORS.Enabled {
+		corsFilter := f.CORS.Build()
+		for _, h := range handlers {
+			h.FilterChain.Add(corsFilter)
+		}
+	}
+	return nil
+}
+
+// AddApplicationFilters adds request timeout and body log if enabled to the
+// filter chain of application handler. It must be called after AddFilters so
+// that bodies are logged uncompressed.
+func (f *commonFactory) AddApplicationFilters(handler *Handler) error {
+	if f.RequestTimeout != "" {
+		timeout, err := time.ParseDuration(f.RequestTimeout)
+		if err != nil {
+			return fmt.Errorf("server: invalid request timeout %v", err)
+		}
+		handler.FilterChain.Add(filter.NewTimeoutFilter(timeout))
+	}
+	if f.BodyLog.Enabled {
+		handler.FilterChain.Add(f.BodyLog.Build())
+	}
 	return nil
 }
 
+// AddAdminFilters adds authentication to the filter chain of admin handler
+// if credentials are configured.
+func (f *commonFactory) AddAdminFilters(handler *Handler) {
+	if f.Admin.Username != "" || f.Admin.Password != "" {
+		handler.FilterChain.Add(auth.NewBasicFilter(f.Admin.Username, f.Admin.Password))
+	}
+}
+
 func (f *commonFactory) getRequestLog(env *core.Environment) (filter.Filter, error) {
-	if f.RequestLog.Value() == nil {
+	return buildRequestLog(env, f.RequestLog)
+}
+
+// buildRequestLog builds the request log filter of the configuration.
+func buildRequestLog(env *core.Environment, config RequestLogConfiguration) (filter.Filter, error) {
+	if config.Value() == nil {
 		return &noRequestLog{}, nil
 	}
-	if requestLogFactory, ok := f.RequestLog.Value().(RequestLogFactory); ok {
+	if requestLogFactory, ok := config.Value().(RequestLogFactory); ok {
 		return requestLogFactory.Build(env)
 	}
-	return nil, fmt.Errorf("server: unsupported request log %#v", f.RequestLog)
+	return nil, fmt.Errorf("server: unsupported request log %#v", config)
 }
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/goburrow/gomelon/core"
+	"github.com/goburrow/gomelon/server/filter"
 )
 
 func TestCommonFactory(t *testing.T) {
@@ -16,3 +20,178 @@ func TestCommonFactory(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCommonFactoryShutdownTimeout(t *testing.T) {
+	factory := commonFactory{}
+	server, err := factory.newServer(core.NewEnvironment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ShutdownTimeout != 30*time.Second {
+		t.Fatalf("unexpected shutdown timeout %v", server.ShutdownTimeout)
+	}
+	factory.ShutdownTimeout = "15s"
+	server, err = factory.newServer(core.NewEnvironment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ShutdownTimeout != 15*time.Second {
+		t.Fatalf("unexpected shutdown timeout %v", server.ShutdownTimeout)
+	}
+	factory.ShutdownTimeout = "15"
+	_, err = factory.newServer(core.NewEnvironment())
+	if err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestCommonFactoryResponseHeaders(t *testing.T) {
+	factory := commonFactory{
+		ResponseHeaders: map[string]string{
+			"X-API-Version":   "1",
+			"X-Frame-Options": "DENY",
+		},
+	}
+	handler := factory.newAppHandler()
+	handler.Handle("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("X-API-Version") != "1" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	if w.Header().Get("X-Frame-Options") != "SAMEORIGIN" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
+
+// testUserFilter sets X-User request header from the query.
+type testUserFilter struct{}
+
+func (*testUserFilter) Name() string {
+	return "user"
+}
+
+func (*testUserFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	r.Header.Set("X-User", r.URL.Query().Get("user"))
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// testAuthFilter rejects requests without X-User header.
+type testAuthFilter struct{}
+
+func (*testAuthFilter) Name() string {
+	return "auth"
+}
+
+func (*testAuthFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	if r.Header.Get("X-User") == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+func TestCommonFactoryFilterChain(t *testing.T) {
+	factory := commonFactory{}
+	handler := factory.newHandler()
+	handler.FilterChain.Add(&testUserFilter{})
+	handler.FilterChain.Add(&testAuthFilter{})
+	handler.Handle("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.Header.Get("X-User")))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/?user=gomelon", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "hello gomelon" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestCommonFactoryPathParams(t *testing.T) {
+	factory := commonFactory{}
+	handler := factory.newHandler()
+	handler.Handle("GET", "/users/:user/posts/:post", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(PathParam(r, "user") + " " + PathParam(r, "post") + " " + PathParam(r, "unknown")))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/users/1/posts/2", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "1 2 " {
+		t.Fatalf("unexpected response %v %q", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/users/1", "/users/1/posts", "/users/1/comments/2"} {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("unexpected code %v for %s", w.Code, path)
+		}
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/users/1/posts/2", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestCommonFactoryRateLimitForwardedFor(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{TrustedProxies: []string{"192.168.0.1"}}
+	factory.RateLimit = filter.RateLimitFactory{Enabled: true, Rate: 1}
+	handler := factory.newHandler()
+	if err := factory.AddFilters(env, handler); err != nil {
+		t.Fatal(err)
+	}
+	handler.Handle("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Client prepends a spoofed address to the header.
+	for i, forwardedFor := range []string{"127.0.0.1", "127.0.0.9, 127.0.0.1", "127.0.0.10, 127.0.0.1"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.168.0.1:1234"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		handler.ServeHTTP(w, r)
+		if (i == 0) != (w.Code == http.StatusOK) {
+			t.Fatalf("unexpected code %v for %s", w.Code, forwardedFor)
+		}
+	}
+}
+
+func TestCommonFactoryApplicationFilters(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := commonFactory{}
+	factory.BodyLog.Enabled = true
+	factory.RequestTimeout = "1s"
+	appHandler := factory.newAppHandler()
+	adminHandler := factory.newHandler()
+	if err := factory.AddFilters(env, appHandler, adminHandler); err != nil {
+		t.Fatal(err)
+	}
+	if appHandler.FilterChain.Contains("bodylog") || adminHandler.FilterChain.Contains("bodylog") {
+		t.Fatal("unexpected body log filter")
+	}
+	if err := factory.AddApplicationFilters(appHandler); err != nil {
+		t.Fatal(err)
+	}
+	factory.AddAdminFilters(adminHandler)
+	for _, name := range []string{"timeout", "bodylog"} {
+		if !appHandler.FilterChain.Contains(name) {
+			t.Fatalf("%s filter expected", name)
+		}
+		if adminHandler.FilterChain.Contains(name) {
+			t.Fatalf("unexpected %s filter in admin", name)
+		}
+	}
+}
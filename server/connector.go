@@ -0,0 +1,427 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goburrow/gol"
+	"golang.org/x/net/context"
+)
+
+const (
+	tcpKeepAlivePeriod = 3 * time.Minute
+
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 60 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+)
+
+type contextKey int
+
+const (
+	connectorKey contextKey = iota
+	pathParamsKey
+)
+
+// Connector utilizes http.Server.
+// Each connector has its own listener which will be closed when closing the
+// server it belongs to. SetHandler() must be called before listening.
+type Connector struct {
+	Type string `valid:"nonzero"`
+	Addr string
+
+	CertFile string
+	KeyFile  string
+	// TLS is used by https connectors.
+	TLS TLSConfiguration
+
+	// TCPNoDelay sets TCP_NODELAY option on accepted connections if it is
+	// specified. It is enabled by default in Go.
+	TCPNoDelay *bool
+	// MaxHeaderBytes is the maximum size of request headers. Default is
+	// http.DefaultMaxHeaderBytes.
+	MaxHeaderBytes int
+	// KeepAlivePeriod is the TCP keep-alive period of accepted connections,
+	// default is 3m. Zero disables keep-alives.
+	KeepAlivePeriod string
+
+	// RedirectHTTPS makes an http connector redirect all requests to https
+	// instead of serving them. HTTPSPort is the port of the https URL,
+	// default is 443.
+	RedirectHTTPS bool
+	HTTPSPort     int
+
+	// ErrorLogger is the logger name for errors of the server, e.g. TLS
+	// handshake errors. Default is "gomelon/server".
+	ErrorLogger string
+
+	// Timeouts of the server in duration format, e.g. "30s". Default values
+	// are used when not set. Admin connectors have no write timeout by
+	// default as profiling and tasks can take long.
+	ReadTimeout       string
+	ReadHeaderTimeout string
+	WriteTimeout      string
+	IdleTimeout       string
+
+	// admin is set for connectors of admin handler.
+	admin bool
+	// server holds the configuration of httpServer.
+	server *http.Server
+	// httpServer is created by open as http.Server can not be reused after
+	// shutting down.
+	httpServer *http.Server
+
+	// listener is stored once listening.
+	listener atomic.Value
+	// serveErr is the error which stopped serving.
+	serveErr atomic.Value
+}
+
+// SetHandler setup the server with the given handler. The handler is not
+// used if the connector redirects to https.
+func (connector *Connector) SetHandler(handler http.Handler) {
+	if connector.server == nil {
+		connector.server = &http.Server{}
+	}
+	if connector.RedirectHTTPS && connector.Type == "http" {
+		handler = &httpsRedirectHandler{port: connector.HTTPSPort}
+	}
+	connector.server.Handler = handler
+}
+
+// configure applies the configuration to the server. SetHandler must be
+// called first.
+func (connector *Connector) configure() error {
+	if err := connector.setTimeouts(); err != nil {
+		return err
+	}
+	errorLogger := connector.ErrorLogger
+	if errorLogger == "" {
+		errorLogger = loggerName
+	}
+	connector.server.MaxHeaderBytes = connector.MaxHeaderBytes
+	connector.server.ErrorLog = log.New(&errorLogWriter{gol.GetLogger(errorLogger)}, "", 0)
+	connector.server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		return context.WithValue(ctx, connectorKey, connector)
+	}
+	if connector.Type == "https" {
+		config, err := connector.TLS.build()
+		if err != nil {
+			return err
+		}
+		connector.server.TLSConfig = config
+	}
+	return nil
+}
+
+// ConnectorFromRequest returns the connector serving the request or nil if
+// it is unknown.
+func ConnectorFromRequest(r *http.Request) *Connector {
+	connector, _ := r.Context().Value(connectorKey).(*Connector)
+	return connector
+}
+
+// IsSecure returns true if the request is received via TLS.
+func IsSecure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	connector := ConnectorFromRequest(r)
+	return connector != nil && connector.Type == "https"
+}
+
+// Validate checks the configuration of the connector.
+func (connector *Connector) Validate() error {
+	return newValidationError(connector.validate())
+}
+
+// validate returns all problems of the configuration.
+func (connector *Connector) validate() []string {
+	var problems []string
+	switch connector.Type {
+	case "http", "unix":
+	case "https":
+		if connector.CertFile == "" {
+			problems = append(problems, "certFile is required for https")
+		}
+		if connector.KeyFile == "" {
+			problems = append(problems, "keyFile is required for https")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported type %q", connector.Type))
+	}
+	if connector.Addr == "" {
+		problems = append(problems, "addr is required")
+	}
+	if connector.RedirectHTTPS && connector.Type != "http" {
+		problems = append(problems, "redirectHTTPS is only supported for http")
+	}
+	if connector.HTTPSPort < 0 || connector.HTTPSPort > 65535 {
+		problems = append(problems, fmt.Sprintf("invalid httpsPort %d", connector.HTTPSPort))
+	}
+	problems = append(problems, validateDuration("readTimeout", connector.ReadTimeout)...)
+	problems = append(problems, validateDuration("readHeaderTimeout", connector.ReadHeaderTimeout)...)
+	problems = append(problems, validateDuration("writeTimeout", connector.WriteTimeout)...)
+	problems = append(problems, validateDuration("idleTimeout", connector.IdleTimeout)...)
+	problems = append(problems, validateDuration("keepAlivePeriod", connector.KeepAlivePeriod)...)
+	if connector.MaxHeaderBytes < 0 {
+		problems = append(problems, fmt.Sprintf("invalid maxHeaderBytes %d", connector.MaxHeaderBytes))
+	}
+	return problems
+}
+
+// setTimeouts parses and sets timeouts of the server.
+func (connector *Connector) setTimeouts() error {
+	writeTimeout := defaultWriteTimeout
+	if connector.admin {
+		writeTimeout = 0
+	}
+	timeouts := []struct {
+		value        string
+		defaultValue time.Duration
+		timeout      *time.Duration
+	}{
+		{connector.ReadTimeout, defaultReadTimeout, &connector.server.ReadTimeout},
+		{connector.ReadHeaderTimeout, defaultReadHeaderTimeout, &connector.server.ReadHeaderTimeout},
+		{connector.WriteTimeout, writeTimeout, &connector.server.WriteTimeout},
+		{connector.IdleTimeout, defaultIdleTimeout, &connector.server.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value == "" {
+			*t.timeout = t.defaultValue
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			return fmt.Errorf("server: invalid timeout %v", err)
+		}
+		*t.timeout = d
+	}
+	return nil
+}
+
+// Listen creates and serves a listerner.
+func (connector *Connector) Listen() error {
+	if err := connector.open(); err != nil {
+		return err
+	}
+	return connector.serve()
+}
+
+// open creates the listener of the connector.
+func (connector *Connector) open() error {
+	listener, err := connector.listen()
+	if err != nil {
+		return err
+	}
+	connector.httpServer = connector.newHTTPServer()
+	connector.listener.Store(listener)
+	return nil
+}
+
+// newHTTPServer creates a new http.Server from the configured server.
+func (connector *Connector) newHTTPServer() *http.Server {
+	s := connector.server
+	return &http.Server{
+		Addr:              connector.Addr,
+		Handler:           s.Handler,
+		TLSConfig:         s.TLSConfig,
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+		ConnContext:       s.ConnContext,
+		ErrorLog:          s.ErrorLog,
+	}
+}
+
+// serve accepts connections on the listener created by open. It returns nil
+// when the server is shutting down. The error is recorded for the health
+// check.
+func (connector *Connector) serve() error {
+	err := connector.httpServer.Serve(connector.listener.Load().(net.Listener))
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	if err != nil {
+		connector.serveErr.Store(serveError{err})
+	}
+	return err
+}
+
+// serveError wraps errors as atomic.Value requires values of the same type.
+type serveError struct {
+	err error
+}
+
+// failure returns the error which stopped serving or nil.
+func (connector *Connector) failure() error {
+	if e, ok := connector.serveErr.Load().(serveError); ok {
+		return e.err
+	}
+	return nil
+}
+
+// wrapError adds type and address of the connector to the error.
+func (connector *Connector) wrapError(err error) error {
+	return fmt.Errorf("server: %s connector %s: %v", connector.Type, connector.Addr, err)
+}
+
+// close closes the listener if it has been created.
+func (connector *Connector) close() {
+	if listener, ok := connector.listener.Load().(net.Listener); ok {
+		listener.Close()
+	}
+}
+
+// LocalAddr returns the network address the connector is listening on, which
+// is useful when the port is assigned by the system (e.g. ":0").
+// It returns nil if the connector is not listening.
+func (connector *Connector) LocalAddr() net.Addr {
+	listener, ok := connector.listener.Load().(net.Listener)
+	if !ok {
+		return nil
+	}
+	return listener.Addr()
+}
+
+// listen creates a listener according to connector type.
+func (connector *Connector) listen() (net.Listener, error) {
+	switch connector.Type {
+	case "http":
+		return connector.listenTCP()
+	case "unix":
+		return connector.listenUnix()
+	case "https":
+		var config *tls.Config
+		if connector.server != nil && connector.server.TLSConfig != nil {
+			config = connector.server.TLSConfig.Clone()
+		} else {
+			config = newTLSConfig()
+		}
+		cert, err := tls.LoadX509KeyPair(connector.CertFile, connector.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+		listener, err := connector.listenTCP()
+		if err != nil {
+			return nil, err
+		}
+		return tls.NewListener(listener, config), nil
+	}
+	return nil, fmt.Errorf("server: unsupported connector type %s", connector.Type)
+}
+
+// listenUnix listens on the unix socket at Addr. A stale socket file is
+// removed first. The socket file is removed when the listener is closed.
+func (connector *Connector) listenUnix() (net.Listener, error) {
+	if fi, err := os.Stat(connector.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(connector.Addr); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", connector.Addr)
+}
+
+func (connector *Connector) listenTCP() (net.Listener, error) {
+	keepAlivePeriod := tcpKeepAlivePeriod
+	if connector.KeepAlivePeriod != "" {
+		var err error
+		if keepAlivePeriod, err = time.ParseDuration(connector.KeepAlivePeriod); err != nil {
+			return nil, fmt.Errorf("server: invalid keep-alive period %v", err)
+		}
+	}
+	listener, err := net.Listen("tcp", connector.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpListener{
+		TCPListener:     listener.(*net.TCPListener),
+		noDelay:         connector.TCPNoDelay,
+		keepAlivePeriod: keepAlivePeriod,
+	}, nil
+}
+
+// tcpListener sets TCP keep-alive timeouts and other options on accepted
+// connections.
+type tcpListener struct {
+	*net.TCPListener
+
+	noDelay *bool
+	// keepAlivePeriod is zero when keep-alives are disabled.
+	keepAlivePeriod time.Duration
+}
+
+func (ln *tcpListener) Accept() (net.Conn, error) {
+	tc, err := ln.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	if ln.keepAlivePeriod > 0 {
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(ln.keepAlivePeriod)
+	} else {
+		tc.SetKeepAlive(false)
+	}
+	if ln.noDelay != nil {
+		tc.SetNoDelay(*ln.noDelay)
+	}
+	return tc, nil
+}
+
+// errorLogWriter forwards server errors to the logger.
+type errorLogWriter struct {
+	logger gol.Logger
+}
+
+func (w *errorLogWriter) Write(b []byte) (int, error) {
+	w.logger.Warn("%s", strings.TrimSuffix(string(b), "\n"))
+	return len(b), nil
+}
+
+// httpsRedirectHandler redirects requests to the same URL with https scheme.
+// 308 Permanent Redirect is used for methods other than GET and HEAD so that
+// clients do not change the method.
+type httpsRedirectHandler struct {
+	port int
+}
+
+func (h *httpsRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hostname := r.Host
+	if name, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = name
+	} else {
+		hostname = strings.Trim(hostname, "[]")
+	}
+	host := hostname
+	if h.port != 0 && h.port != 443 {
+		host = net.JoinHostPort(hostname, strconv.Itoa(h.port))
+	} else if strings.Contains(hostname, ":") {
+		// IPv6
+		host = "[" + hostname + "]"
+	}
+	u := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     r.URL.Path,
+		RawPath:  r.URL.RawPath,
+		RawQuery: r.URL.RawQuery,
+	}
+	code := http.StatusMovedPermanently
+	if r.Method != "GET" && r.Method != "HEAD" {
+		code = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, u.String(), code)
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/goburrow/gol"
+	"golang.org/x/net/context"
+)
+
+func TestConnectorTCPNoDelay(t *testing.T) {
+	noDelay := false
+	connector := &Connector{
+		Type:       "http",
+		Addr:       "127.0.0.1:0",
+		TCPNoDelay: &noDelay,
+	}
+	listener, err := connector.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	ln, ok := listener.(*tcpListener)
+	if !ok || ln.noDelay == nil || *ln.noDelay {
+		t.Fatalf("unexpected listener %#v", listener)
+	}
+
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		t.Fatalf("unexpected connection %#v", conn)
+	}
+	rc, err := tc.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int
+	err = rc.Control(func(fd uintptr) {
+		value, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 0 {
+		t.Fatalf("unexpected TCP_NODELAY %v", value)
+	}
+}
+
+func TestConnectorUnsupportedType(t *testing.T) {
+	connector := &Connector{
+		Type: "ftp",
+		Addr: "127.0.0.1:0",
+	}
+	_, err := connector.listen()
+	if err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestConnectorTimeouts(t *testing.T) {
+	connector := &Connector{
+		Type:         "http",
+		ReadTimeout:  "5s",
+		WriteTimeout: "1m",
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.setTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+	if connector.server.ReadTimeout != 5*time.Second {
+		t.Fatalf("unexpected read timeout %v", connector.server.ReadTimeout)
+	}
+	if connector.server.WriteTimeout != time.Minute {
+		t.Fatalf("unexpected write timeout %v", connector.server.WriteTimeout)
+	}
+	if connector.server.ReadHeaderTimeout != defaultReadHeaderTimeout {
+		t.Fatalf("unexpected read header timeout %v", connector.server.ReadHeaderTimeout)
+	}
+	if connector.server.IdleTimeout != defaultIdleTimeout {
+		t.Fatalf("unexpected idle timeout %v", connector.server.IdleTimeout)
+	}
+}
+
+func TestConnectorAdminTimeouts(t *testing.T) {
+	connector := &Connector{
+		Type:  "http",
+		admin: true,
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.setTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+	if connector.server.WriteTimeout != 0 {
+		t.Fatalf("unexpected write timeout %v", connector.server.WriteTimeout)
+	}
+	if connector.server.ReadTimeout != defaultReadTimeout {
+		t.Fatalf("unexpected read timeout %v", connector.server.ReadTimeout)
+	}
+	connector.WriteTimeout = "10m"
+	if err := connector.setTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+	if connector.server.WriteTimeout != 10*time.Minute {
+		t.Fatalf("unexpected write timeout %v", connector.server.WriteTimeout)
+	}
+}
+
+func TestConnectorInvalidTimeout(t *testing.T) {
+	connector := &Connector{
+		Type:        "http",
+		IdleTimeout: "forever",
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.setTimeouts(); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestConnectorLocalAddr(t *testing.T) {
+	connector := &Connector{
+		Type: "http",
+		Addr: "127.0.0.1:0",
+	}
+	if connector.LocalAddr() != nil {
+		t.Fatalf("unexpected address %v", connector.LocalAddr())
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	go connector.Listen()
+
+	var addr net.Addr
+	for i := 0; i < 100 && addr == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		addr = connector.LocalAddr()
+	}
+	if addr == nil {
+		t.Fatal("connector is not listening")
+	}
+	defer connector.listener.Load().(net.Listener).Close()
+
+	resp, err := http.Get("http://" + addr.String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unexpected status %v", resp.StatusCode)
+	}
+}
+
+func TestConnectorUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr := filepath.Join(dir, "test.sock")
+	// Stale socket
+	stale, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	connector := &Connector{
+		Type: "unix",
+		Addr: addr,
+	}
+	listener, err := connector.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listener.Addr().Network() != "unix" {
+		t.Fatalf("unexpected address %v", listener.Addr())
+	}
+	listener.Close()
+	if _, err = os.Stat(addr); !os.IsNotExist(err) {
+		t.Fatalf("socket file is not removed: %v", err)
+	}
+}
+
+type testLogger struct {
+	gol.Logger
+	messages []string
+}
+
+func (l *testLogger) Warn(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestConnectorErrorLog(t *testing.T) {
+	connector := &Connector{
+		Type:        "http",
+		ErrorLogger: "gomelon/server/test",
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.configure(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := connector.server.ErrorLog.Writer().(*errorLogWriter); !ok {
+		t.Fatalf("unexpected error log writer %#v", connector.server.ErrorLog.Writer())
+	}
+	logger := &testLogger{}
+	connector.server.ErrorLog.SetOutput(&errorLogWriter{logger})
+	connector.server.ErrorLog.Printf("http: TLS handshake error")
+	if len(logger.messages) != 1 || logger.messages[0] != "http: TLS handshake error" {
+		t.Fatalf("unexpected messages %v", logger.messages)
+	}
+}
+
+func TestConnectorFromRequest(t *testing.T) {
+	var connector *Connector
+	var secure bool
+	connector = &Connector{
+		Type: "http",
+		Addr: "127.0.0.1:0",
+	}
+	connector.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ConnectorFromRequest(r) != connector {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		secure = IsSecure(r)
+	}))
+	if err := connector.configure(); err != nil {
+		t.Fatal(err)
+	}
+	if err := connector.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer connector.close()
+	go connector.serve()
+
+	resp, err := http.Get("http://" + connector.LocalAddr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %v", resp.StatusCode)
+	}
+	if secure {
+		t.Fatal("request must not be secure")
+	}
+}
+
+func TestIsSecure(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	if IsSecure(r) {
+		t.Fatal("request must not be secure")
+	}
+	connector := &Connector{Type: "https"}
+	r = r.WithContext(context.WithValue(r.Context(), connectorKey, connector))
+	if !IsSecure(r) {
+		t.Fatal("request must be secure")
+	}
+}
+
+func TestConnectorRedirectHTTPS(t *testing.T) {
+	connector := &Connector{Type: "http", Addr: ":80", RedirectHTTPS: true}
+	connector.SetHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	}))
+	data := []struct {
+		port     int
+		method   string
+		url      string
+		code     int
+		location string
+	}{
+		{0, "GET", "http://example.com:8080/a/b?c=d", http.StatusMovedPermanently, "https://example.com/a/b?c=d"},
+		{443, "HEAD", "http://example.com/", http.StatusMovedPermanently, "https://example.com/"},
+		{8443, "POST", "http://example.com/a%2Fb", http.StatusPermanentRedirect, "https://example.com:8443/a%2Fb"},
+		{8443, "GET", "http://[::1]:8080/", http.StatusMovedPermanently, "https://[::1]:8443/"},
+		{0, "GET", "http://[::1]/", http.StatusMovedPermanently, "https://[::1]/"},
+	}
+	for _, d := range data {
+		connector.HTTPSPort = d.port
+		connector.SetHandler(nil)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest(d.method, d.url, nil)
+		connector.server.Handler.ServeHTTP(w, r)
+		if w.Code != d.code || w.Header().Get("Location") != d.location {
+			t.Fatalf("unexpected response %v %v for %+v", w.Code, w.Header(), d)
+		}
+	}
+}
+
+func TestConnectorRedirectHTTPSInvalid(t *testing.T) {
+	connector := &Connector{Type: "https", Addr: ":443", CertFile: "cert", KeyFile: "key", RedirectHTTPS: true}
+	if err := connector.Validate(); err == nil || !strings.Contains(err.Error(), "redirectHTTPS") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestConnectorKeepAlivePeriod(t *testing.T) {
+	data := map[string]time.Duration{
+		"":    tcpKeepAlivePeriod,
+		"30s": 30 * time.Second,
+		"0":   0,
+	}
+	for period, expected := range data {
+		connector := &Connector{
+			Type:            "http",
+			Addr:            "127.0.0.1:0",
+			KeepAlivePeriod: period,
+		}
+		listener, err := connector.listen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if listener.(*tcpListener).keepAlivePeriod != expected {
+			t.Fatalf("unexpected keep-alive period %v, expected %v", listener.(*tcpListener).keepAlivePeriod, expected)
+		}
+		go func() {
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			if err == nil {
+				conn.Close()
+			}
+		}()
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		listener.Close()
+	}
+	connector := &Connector{Type: "http", Addr: "127.0.0.1:0", KeepAlivePeriod: "1"}
+	if _, err := connector.listen(); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestConnectorMaxHeaderBytes(t *testing.T) {
+	connector := &Connector{Type: "http", Addr: "127.0.0.1:0", MaxHeaderBytes: 4096}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.configure(); err != nil {
+		t.Fatal(err)
+	}
+	if connector.server.MaxHeaderBytes != 4096 {
+		t.Fatalf("unexpected max header bytes %v", connector.server.MaxHeaderBytes)
+	}
+	connector.MaxHeaderBytes = -1
+	if err := connector.Validate(); err == nil {
+		t.Fatal("error expected")
+	}
+}
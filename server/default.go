@@ -1,8 +1,6 @@
 package server
 
 import (
-	"net/http"
-
 	"github.com/goburrow/gomelon/core"
 )
 
@@ -13,31 +11,62 @@ type DefaultFactory struct {
 
 	ApplicationConnectors []Connector `valid:"nonzero"`
 	AdminConnectors       []Connector `valid:"nonzero"`
+	// ApplicationContextPath and AdminContextPath are optional path
+	// prefixes of the application and admin handlers, e.g. "/api".
+	ApplicationContextPath string
+	AdminContextPath       string
 }
 
 var _ core.ServerFactory = (*DefaultFactory)(nil)
 
+// Validate checks the configuration of the factory and all its connectors.
+func (factory *DefaultFactory) Validate() error {
+	problems := factory.commonFactory.validate()
+	if len(factory.ApplicationConnectors) == 0 {
+		problems = append(problems, "at least one application connector is required")
+	}
+	problems = append(problems, validateConnectors("applicationConnectors", factory.ApplicationConnectors)...)
+	problems = append(problems, validateConnectors("adminConnectors", factory.AdminConnectors)...)
+	return newValidationError(problems)
+}
+
 func (factory *DefaultFactory) Build(env *core.Environment) (core.Server, error) {
+	if err := factory.Validate(); err != nil {
+		return nil, err
+	}
 	// Application
-	appHandler := NewHandler()
-	appHandler.ServeMux.Use(func(h http.Handler) http.Handler {
-		return appHandler.FilterChain.Build(h)
-	})
+	appHandler := factory.newAppHandler()
+	appHandler.pathPrefix = normalizeContextPath(factory.ApplicationContextPath)
 	env.Server.ServerHandler = appHandler
 	env.Server.AddResourceHandler(newResourceHandler(appHandler, env.Server))
 
 	// Admin
-	adminHandler := NewHandler()
-	adminHandler.ServeMux.Use(func(h http.Handler) http.Handler {
-		return adminHandler.FilterChain.Build(h)
-	})
+	adminHandler := factory.newHandler()
+	adminHandler.pathPrefix = normalizeContextPath(factory.AdminContextPath)
 	env.Admin.ServerHandler = adminHandler
 
 	if err := factory.commonFactory.AddFilters(env, appHandler, adminHandler); err != nil {
 		return nil, err
 	}
-	server := NewServer()
-	server.addConnectors(appHandler.ServeMux, factory.ApplicationConnectors)
-	server.addConnectors(adminHandler.ServeMux, factory.AdminConnectors)
+	if err := factory.commonFactory.AddApplicationFilters(appHandler); err != nil {
+		return nil, err
+	}
+	factory.commonFactory.AddAdminFilters(adminHandler)
+	server, err := factory.newServer(env)
+	if err != nil {
+		return nil, err
+	}
+	if err = server.addConnectors(factory.wrapHandler(env, appHandler), factory.ApplicationConnectors); err != nil {
+		return nil, err
+	}
+	for i := range factory.AdminConnectors {
+		factory.AdminConnectors[i].admin = true
+	}
+	if err = server.addConnectors(adminHandler, factory.AdminConnectors); err != nil {
+		return nil, err
+	}
+	if err = factory.configureAdmin(env, server); err != nil {
+		return nil, err
+	}
 	return server, nil
 }
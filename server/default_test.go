@@ -1,14 +1,20 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/codahale/metrics"
 	"github.com/goburrow/gomelon/core"
 )
 
 func TestDefaultFactory(t *testing.T) {
 	env := core.NewEnvironment()
-	factory := &DefaultFactory{}
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
 
 	s, err := factory.Build(env)
 	if err != nil {
@@ -24,3 +30,203 @@ func TestDefaultFactory(t *testing.T) {
 		t.Fatal("Admin.ServerHandler is nil")
 	}
 }
+
+func TestDefaultFactoryHandlerWrapper(t *testing.T) {
+	env := core.NewEnvironment()
+	env.Server.HandlerWrapper = func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Wrapper", "1")
+			h.ServeHTTP(w, r)
+		})
+	}
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+		AdminConnectors:       []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectors := s.(*Server).Connectors
+	if len(connectors) != 2 {
+		t.Fatalf("unexpected connectors %#v", connectors)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	connectors[0].server.Handler.ServeHTTP(w, r)
+	if w.Header().Get("X-Wrapper") != "1" {
+		t.Fatalf("application handler is not wrapped %#v", w.Header())
+	}
+	w = httptest.NewRecorder()
+	connectors[1].server.Handler.ServeHTTP(w, r)
+	if w.Header().Get("X-Wrapper") != "" {
+		t.Fatalf("admin handler must not be wrapped %#v", w.Header())
+	}
+	if connectors[0].server.WriteTimeout != defaultWriteTimeout || connectors[1].server.WriteTimeout != 0 {
+		t.Fatalf("unexpected write timeouts %v %v", connectors[0].server.WriteTimeout, connectors[1].server.WriteTimeout)
+	}
+}
+
+func TestDefaultFactoryAdminAuth(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+		AdminConnectors:       []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
+	factory.Admin.Username = "admin"
+	factory.Admin.Password = "secret"
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Admin.ServerHandler.Handle("GET", "/ping", http.NotFoundHandler())
+	adminConnector := s.(*Server).Connectors[1]
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/ping", nil)
+	adminConnector.server.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.SetBasicAuth("admin", "secret")
+	adminConnector.server.Handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestDefaultFactoryRouteMetrics(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
+	if _, err := factory.Build(env); err != nil {
+		t.Fatal(err)
+	}
+	env.Server.ServerHandler.Handle("GET", "/metrics-test/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	handler := env.Server.ServerHandler.(*Handler)
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		handler.ServeMux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected code %v", w.Code)
+		}
+	}
+	// Made-up methods share the same metrics.
+	env.Server.ServerHandler.Handle("*", "/metrics-any", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	}))
+	for _, method := range []string{"FOO", "BAR"} {
+		r, _ := http.NewRequest(method, "/metrics-any", nil)
+		handler.ServeMux.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	counters, _ := metrics.Snapshot()
+	if counters["HTTP.Routes.GET /metrics-test/:id.Requests"] != 2 ||
+		counters["HTTP.Routes.OTHER /metrics-any.Requests"] != 2 {
+		t.Fatalf("unexpected counters %v", counters)
+	}
+	for name := range counters {
+		if strings.Contains(name, "FOO") || strings.Contains(name, "BAR") {
+			t.Fatalf("unexpected counter %v", name)
+		}
+	}
+}
+
+func TestDefaultFactoryContextPath(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &DefaultFactory{
+		ApplicationConnectors:  []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+		AdminConnectors:        []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+		ApplicationContextPath: "api/",
+		AdminContextPath:       "/admin",
+	}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Server.ServerHandler.PathPrefix() != "/api" || env.Admin.ServerHandler.PathPrefix() != "/admin" {
+		t.Fatalf("unexpected path prefixes %v %v",
+			env.Server.ServerHandler.PathPrefix(), env.Admin.ServerHandler.PathPrefix())
+	}
+	env.Server.ServerHandler.Handle("GET", "/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	connectors := s.(*Server).Connectors
+	data := map[string]int{
+		"/api/foo": http.StatusOK,
+		"/foo":     http.StatusNotFound,
+		"/apifoo":  http.StatusNotFound,
+	}
+	for path, code := range data {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		connectors[0].server.Handler.ServeHTTP(w, r)
+		if w.Code != code {
+			t.Fatalf("unexpected code %v for %s", w.Code, path)
+		}
+	}
+}
+
+func TestNormalizeContextPath(t *testing.T) {
+	data := map[string]string{
+		"":      "",
+		"/":     "",
+		"api":   "/api",
+		"/api":  "/api",
+		"api/":  "/api",
+		"/api/": "/api",
+		"/a/b/": "/a/b",
+	}
+	for path, expected := range data {
+		if actual := normalizeContextPath(path); actual != expected {
+			t.Fatalf("unexpected context path %q for %q, expected %q", actual, path, expected)
+		}
+	}
+}
+
+func TestDefaultFactoryEnvironmentFilter(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = env.AddFilter(&testAuthFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = env.AddFilterBefore(&testUserFilter{}, "auth"); err != nil {
+		t.Fatal(err)
+	}
+	if err = env.AddFilterBefore(&testUserFilter{}, "unknown"); err == nil || err.Error() != "server: filter unknown not found" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = env.AddFilter(http.NotFoundHandler()); err == nil {
+		t.Fatal("error expected")
+	}
+	env.Server.ServerHandler.Handle("GET", "/user", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-User")))
+	}))
+	handler := s.(*Server).Connectors[0].server.Handler
+
+	data := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/user?user=melon", http.StatusOK, "melon"},
+		{"/user", http.StatusUnauthorized, "Unauthorized\n"},
+	}
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", d.path, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != d.code || w.Body.String() != d.body {
+			t.Fatalf("unexpected response for %s: %v %q", d.path, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package filter
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	bodyLimitFilterName = "bodylimit"
+
+	defaultMaxBodySize = 10 << 20
+)
+
+// BodyLimitFactory is the configuration of request body size limits.
+type BodyLimitFactory struct {
+	// Enabled adds body limit filter to the filter chain.
+	Enabled bool
+	// MaxBodySize is the maximum number of bytes of request bodies.
+	// Default is 10MB.
+	MaxBodySize int64
+	// Paths overrides the maximum size for request paths starting with the
+	// given prefixes, e.g. "/upload/". The longest prefix is used.
+	Paths map[string]int64
+}
+
+// Build creates a new BodyLimitFilter from the configuration.
+func (f *BodyLimitFactory) Build() *BodyLimitFilter {
+	maxBodySize := f.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	filter := NewBodyLimitFilter(maxBodySize)
+	for path, limit := range f.Paths {
+		filter.SetLimit(path, limit)
+	}
+	return filter
+}
+
+// BodyLimitFilter limits size of request bodies. 413 Request Entity Too
+// Large is responded if Content-Length exceeds the limit or the handler reads
+// more than the limit from the body, in which case reading returns an error
+// and the response of the handler is discarded.
+type BodyLimitFilter struct {
+	limit int64
+	paths map[string]int64
+}
+
+var _ Filter = (*BodyLimitFilter)(nil)
+
+// NewBodyLimitFilter allocates and returns a new BodyLimitFilter with the
+// given maximum number of bytes.
+func NewBodyLimitFilter(limit int64) *BodyLimitFilter {
+	return &BodyLimitFilter{
+		limit: limit,
+		paths: make(map[string]int64),
+	}
+}
+
+// SetLimit sets the limit for paths starting with the given prefix.
+// SetLimit is not concurrent-safe.
+func (f *BodyLimitFilter) SetLimit(pathPrefix string, limit int64) {
+	f.paths[pathPrefix] = limit
+}
+
+func (f *BodyLimitFilter) Name() string {
+	return bodyLimitFilterName
+}
+
+func (f *BodyLimitFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	limit := f.getLimit(r.URL.Path)
+	if r.ContentLength > limit {
+		http.Error(w, "Request entity too large.", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		chain[0].ServeHTTP(w, r, chain[1:])
+		return
+	}
+	lw := &bodyLimitWriter{ResponseWriter: NewResponseWriter(w)}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Body = &bodyLimitReader{http.MaxBytesReader(w, r.Body, limit), lw}
+	chain[0].ServeHTTP(lw, r2, chain[1:])
+	lw.start()
+}
+
+// getLimit returns limit of the longest matched path prefix.
+func (f *BodyLimitFilter) getLimit(path string) int64 {
+	limit := f.limit
+	matched := -1
+	for prefix, l := range f.paths {
+		if len(prefix) > matched && strings.HasPrefix(path, prefix) {
+			limit = l
+			matched = len(prefix)
+		}
+	}
+	return limit
+}
+
+// bodyLimitReader notifies the writer when the body exceeds the limit.
+type bodyLimitReader struct {
+	io.ReadCloser
+	w *bodyLimitWriter
+}
+
+func (r *bodyLimitReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if _, ok := err.(*http.MaxBytesError); ok {
+		r.w.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimitWriter responds 413 instead of the handler response once the
+// request body has exceeded the limit, unless the response has been started.
+type bodyLimitWriter struct {
+	*ResponseWriter
+	exceeded  bool
+	started   bool
+	discarded bool
+}
+
+// start responds 413 if the limit has been exceeded when the response is
+// started.
+func (w *bodyLimitWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if w.exceeded {
+		w.discarded = true
+		http.Error(w.ResponseWriter, "Request entity too large.", http.StatusRequestEntityTooLarge)
+	}
+}
+
+func (w *bodyLimitWriter) WriteHeader(status int) {
+	w.start()
+	if !w.discarded {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *bodyLimitWriter) Write(b []byte) (int, error) {
+	w.start()
+	if w.discarded {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLimitWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bodyLimitWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{w}, r)
+}
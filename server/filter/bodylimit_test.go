@@ -0,0 +1,79 @@
+package filter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimitFilter(t *testing.T) {
+	factory := BodyLimitFactory{
+		MaxBodySize: 10,
+		Paths: map[string]int64{
+			"/upload/":       20,
+			"/upload/small/": 5,
+		},
+	}
+	builder := NewChain()
+	builder.Add(factory.Build())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}))
+	data := []struct {
+		path string
+		size int
+		code int
+	}{
+		{"/", 10, http.StatusOK},
+		{"/", 11, http.StatusRequestEntityTooLarge},
+		{"/upload/", 20, http.StatusOK},
+		{"/upload/", 21, http.StatusRequestEntityTooLarge},
+		{"/upload/small/", 5, http.StatusOK},
+		{"/upload/small/", 6, http.StatusRequestEntityTooLarge},
+	}
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", d.path, strings.NewReader(strings.Repeat("a", d.size)))
+		chain.ServeHTTP(w, r)
+		if w.Code != d.code {
+			t.Fatalf("unexpected code %v for %+v", w.Code, d)
+		}
+		// Unknown content length
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("POST", d.path, ioutil.NopCloser(strings.NewReader(strings.Repeat("a", d.size))))
+		r.ContentLength = -1
+		chain.ServeHTTP(w, r)
+		if w.Code != d.code {
+			t.Fatalf("unexpected code %v for %+v with unknown length", w.Code, d)
+		}
+	}
+}
+
+func TestBodyLimitFilterDefault(t *testing.T) {
+	factory := BodyLimitFactory{Enabled: true}
+	builder := NewChain()
+	builder.Add(factory.Build())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Error is ignored
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	for _, size := range []int{1, defaultMaxBodySize + 1} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(strings.Repeat("a", size))))
+		r.ContentLength = -1
+		chain.ServeHTTP(w, r)
+		if size <= defaultMaxBodySize {
+			if w.Code != http.StatusOK || w.Body.String() != "ok" {
+				t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+			}
+		} else if w.Code != http.StatusRequestEntityTooLarge || w.Body.String() != "Request entity too large.\n" {
+			t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/gol"
+)
+
+const (
+	bodyLogFilterName = "bodylog"
+	bodyLogLoggerName = "gomelon/server/filter/bodylog"
+
+	defaultBodyLogMaxLength = 1024
+)
+
+// defaultBodyLogContentTypes are prefixes of content types which are logged.
+var defaultBodyLogContentTypes = []string{
+	"application/json",
+	"application/xml",
+	"application/x-www-form-urlencoded",
+	"text/",
+}
+
+// BodyLogFactory is the configuration of request and response body logging.
+// Bodies may contain sensitive data so it should only be enabled for
+// debugging. Bodies are logged at DEBUG level of logger
+// "gomelon/server/filter/bodylog".
+type BodyLogFactory struct {
+	// Enabled adds body log filter to the filter chain.
+	Enabled bool
+	// MaxLength is the maximum number of bytes of each body logged.
+	// Default is 1024.
+	MaxLength int
+	// ContentTypes are prefixes of content types which are logged. JSON,
+	// XML, form and text bodies are logged by default.
+	ContentTypes []string
+}
+
+// Build creates a new BodyLogFilter from the configuration.
+func (f *BodyLogFactory) Build() *BodyLogFilter {
+	filter := NewBodyLogFilter()
+	if f.MaxLength > 0 {
+		filter.MaxLength = f.MaxLength
+	}
+	if len(f.ContentTypes) > 0 {
+		filter.ContentTypes = f.ContentTypes
+	}
+	return filter
+}
+
+// BodyLogFilter logs truncated request and response bodies. The handler
+// still reads and writes the whole bodies. Nothing is recorded unless DEBUG
+// level is enabled for its logger.
+type BodyLogFilter struct {
+	MaxLength    int
+	ContentTypes []string
+
+	logger gol.Logger
+}
+
+var _ Filter = (*BodyLogFilter)(nil)
+
+// NewBodyLogFilter allocates and returns a new BodyLogFilter with default
+// settings.
+func NewBodyLogFilter() *BodyLogFilter {
+	return &BodyLogFilter{
+		MaxLength:    defaultBodyLogMaxLength,
+		ContentTypes: defaultBodyLogContentTypes,
+		logger:       gol.GetLogger(bodyLogLoggerName),
+	}
+}
+
+func (f *BodyLogFilter) Name() string {
+	return bodyLogFilterName
+}
+
+func (f *BodyLogFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	if !f.logger.DebugEnabled() {
+		chain[0].ServeHTTP(w, r, chain[1:])
+		return
+	}
+	var requestBody *limitedBuffer
+	if r.Body != nil && r.Body != http.NoBody && f.isLogged(r.Header.Get("Content-Type")) {
+		requestBody = &limitedBuffer{max: f.MaxLength}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.Body = &teeReadCloser{r.Body, requestBody}
+		r = r2
+	}
+	bw := &bodyLogWriter{
+		ResponseWriter: NewResponseWriter(w),
+		body:           limitedBuffer{max: f.MaxLength},
+	}
+	chain[0].ServeHTTP(bw, r, chain[1:])
+
+	if requestBody != nil {
+		f.logger.Debug("%s %s request body: %s", r.Method, r.URL.Path, requestBody)
+	}
+	if f.isLogged(bw.Header().Get("Content-Type")) {
+		f.logger.Debug("%s %s response body (%d): %s", r.Method, r.URL.Path, bw.Status(), &bw.body)
+	}
+}
+
+// isLogged returns true if the content type matches one of ContentTypes.
+func (f *BodyLogFilter) isLogged(contentType string) bool {
+	for _, t := range f.ContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer stores up to max bytes written.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "...(truncated)"
+	}
+	return b.buf.String()
+}
+
+// teeReadCloser records what is read from the request body.
+type teeReadCloser struct {
+	io.ReadCloser
+	w io.Writer
+}
+
+func (r *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.w.Write(p[:n])
+	}
+	return n, err
+}
+
+// bodyLogWriter records what is written to the response body.
+type bodyLogWriter struct {
+	*ResponseWriter
+	body limitedBuffer
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bodyLogWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{w}, r)
+}
@@ -0,0 +1,95 @@
+package filter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goburrow/gol"
+)
+
+func TestBodyLogFilter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := gol.GetLogger(bodyLogLoggerName).(*gol.DefaultLogger)
+	level := logger.Level()
+	logger.SetLevel(gol.LevelDebug)
+	logger.SetAppender(gol.NewAppender(&buf))
+	defer func() {
+		logger.SetLevel(level)
+		logger.SetAppender(nil)
+	}()
+
+	factory := BodyLogFactory{Enabled: true, MaxLength: 10}
+	builder := NewChain()
+	builder.Add(factory.Build())
+	var received string
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":"created"}`))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/items", strings.NewReader(`{"name":"melon"}`))
+	r.Header.Set("Content-Type", "application/json")
+	chain.ServeHTTP(w, r)
+
+	if received != `{"name":"melon"}` {
+		t.Fatalf("unexpected request body: %q", received)
+	}
+	if w.Body.String() != `{"result":"created"}` {
+		t.Fatalf("unexpected response body: %q", w.Body.String())
+	}
+	logs := buf.String()
+	if !strings.Contains(logs, `POST /items request body: {"name":"m...(truncated)`) {
+		t.Fatalf("unexpected request log: %s", logs)
+	}
+	if !strings.Contains(logs, `POST /items response body (200): {"result":...(truncated)`) {
+		t.Fatalf("unexpected response log: %s", logs)
+	}
+
+	// Content type is not logged
+	buf.Reset()
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("POST", "/upload", strings.NewReader("binary"))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	chain.ServeHTTP(w, r)
+	if received != "binary" {
+		t.Fatalf("unexpected request body: %q", received)
+	}
+	if strings.Contains(buf.String(), "binary") {
+		t.Fatalf("unexpected log: %s", buf.String())
+	}
+}
+
+func TestBodyLogFilterDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	logger := gol.GetLogger(bodyLogLoggerName).(*gol.DefaultLogger)
+	level := logger.Level()
+	logger.SetLevel(gol.LevelInfo)
+	logger.SetAppender(gol.NewAppender(&buf))
+	defer func() {
+		logger.SetLevel(level)
+		logger.SetAppender(nil)
+	}()
+
+	builder := NewChain()
+	builder.Add(NewBodyLogFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "hello" {
+		t.Fatalf("unexpected response body: %q", w.Body.String())
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected log: %s", buf.String())
+	}
+}
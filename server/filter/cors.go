@@ -0,0 +1,171 @@
+package filter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	corsFilterName = "cors"
+)
+
+var (
+	defaultCORSAllowedMethods = []string{"GET", "POST", "HEAD"}
+	defaultCORSAllowedHeaders = []string{"X-Requested-With", "Content-Type", "Accept", "Origin"}
+)
+
+// CORSFactory is the configuration of cross-origin resource sharing.
+type CORSFactory struct {
+	// Enabled adds CORS filter to the filter chain.
+	Enabled bool
+	// AllowedOrigins are origins allowed to access resources, e.g.
+	// "https://example.com". "*" allows all origins without credentials.
+	AllowedOrigins []string
+	// AllowedMethods are methods allowed in preflight requests.
+	// Default is GET, POST and HEAD.
+	AllowedMethods []string
+	// AllowedHeaders are headers allowed in preflight requests.
+	// Default is X-Requested-With, Content-Type, Accept and Origin.
+	AllowedHeaders []string
+	// AllowCredentials indicates whether requests can include credentials.
+	// It only applies to origins listed explicitly.
+	AllowCredentials bool
+	// PreflightMaxAge is the number of seconds preflight results can be
+	// cached by clients. It is not sent if zero.
+	PreflightMaxAge int
+}
+
+// Build creates a new CORSFilter from the configuration.
+func (f *CORSFactory) Build() *CORSFilter {
+	filter := NewCORSFilter(f.AllowedOrigins...)
+	if len(f.AllowedMethods) > 0 {
+		filter.AllowedMethods = f.AllowedMethods
+	}
+	if len(f.AllowedHeaders) > 0 {
+		filter.AllowedHeaders = f.AllowedHeaders
+	}
+	filter.AllowCredentials = f.AllowCredentials
+	filter.PreflightMaxAge = f.PreflightMaxAge
+	return filter
+}
+
+// CORSFilter handles cross-origin requests. The request Origin is echoed back
+// when it is listed in AllowedOrigins, otherwise "*" is sent without
+// credentials if all origins are allowed.
+type CORSFilter struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	PreflightMaxAge  int
+}
+
+var _ Filter = (*CORSFilter)(nil)
+
+// NewCORSFilter allocates and returns a new CORSFilter allowing the given
+// origins with default methods and headers.
+func NewCORSFilter(allowedOrigins ...string) *CORSFilter {
+	return &CORSFilter{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: defaultCORSAllowedMethods,
+		AllowedHeaders: defaultCORSAllowedHeaders,
+	}
+}
+
+func (f *CORSFilter) Name() string {
+	return corsFilterName
+}
+
+func (f *CORSFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		chain[0].ServeHTTP(w, r, chain[1:])
+		return
+	}
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	allowOrigin := f.allowOrigin(origin)
+	if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+		f.preflight(w, r, allowOrigin)
+		return
+	}
+	if allowOrigin != "" {
+		f.setAllowOrigin(header, allowOrigin)
+	}
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// preflight responds to preflight request without calling next filters.
+func (f *CORSFilter) preflight(w http.ResponseWriter, r *http.Request, allowOrigin string) {
+	if allowOrigin == "" || !contains(f.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) ||
+		!f.areHeadersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	header := w.Header()
+	f.setAllowOrigin(header, allowOrigin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(f.AllowedMethods, ","))
+	header.Set("Access-Control-Allow-Headers", strings.Join(f.AllowedHeaders, ","))
+	if f.PreflightMaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(f.PreflightMaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin returns the origin if it is listed, "*" if all origins are
+// allowed or empty if it is not allowed.
+func (f *CORSFilter) allowOrigin(origin string) string {
+	wildcard := false
+	for _, o := range f.AllowedOrigins {
+		if o == "*" {
+			wildcard = true
+		} else if strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	if wildcard {
+		return "*"
+	}
+	return ""
+}
+
+// setAllowOrigin sets Access-Control-Allow-Origin header. Credentials are
+// never allowed for all origins as any site could read responses of
+// credentialed requests.
+func (f *CORSFilter) setAllowOrigin(header http.Header, allowOrigin string) {
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if f.AllowCredentials && allowOrigin != "*" {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// areHeadersAllowed checks comma-separated headers requested in preflight.
+func (f *CORSFilter) areHeadersAllowed(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		found := false
+		for _, allowed := range f.AllowedHeaders {
+			if strings.EqualFold(h, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCORSChain(f *CORSFilter) *Chain {
+	builder := NewChain()
+	builder.Add(f)
+	return builder.Build(http.HandlerFunc(end))
+}
+
+func TestCORSFilterPreflight(t *testing.T) {
+	f := NewCORSFilter("https://example.com")
+	f.AllowCredentials = true
+	f.PreflightMaxAge = 600
+	chain := newCORSChain(f)
+
+	tests := []struct {
+		origin  string
+		method  string
+		headers string
+		code    int
+	}{
+		{"https://example.com", "POST", "content-type, accept", http.StatusNoContent},
+		{"https://example.com", "DELETE", "", http.StatusForbidden},
+		{"https://example.com", "GET", "X-Custom", http.StatusForbidden},
+		{"https://other.com", "GET", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("OPTIONS", "/", nil)
+		r.Header.Set("Origin", test.origin)
+		r.Header.Set("Access-Control-Request-Method", test.method)
+		r.Header.Set("Access-Control-Request-Headers", test.headers)
+		chain.ServeHTTP(w, r)
+		if w.Code != test.code || w.Body.Len() != 0 {
+			t.Fatalf("unexpected response %v %v for %+v", w.Code, w.Body.String(), test)
+		}
+		if test.code != http.StatusNoContent {
+			if w.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Fatalf("unexpected headers %v", w.Header())
+			}
+			continue
+		}
+		header := w.Header()
+		if header.Get("Access-Control-Allow-Origin") != test.origin ||
+			header.Get("Access-Control-Allow-Methods") != "GET,POST,HEAD" ||
+			header.Get("Access-Control-Allow-Headers") != "X-Requested-With,Content-Type,Accept,Origin" ||
+			header.Get("Access-Control-Allow-Credentials") != "true" ||
+			header.Get("Access-Control-Max-Age") != "600" {
+			t.Fatalf("unexpected headers %v", header)
+		}
+	}
+}
+
+func TestCORSFilterSimple(t *testing.T) {
+	chain := newCORSChain(NewCORSFilter("*"))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "END" {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "" ||
+		w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	// Same origin
+	w = httptest.NewRecorder()
+	r.Header.Del("Origin")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "END" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unexpected response %v %v", w.Header(), w.Body.String())
+	}
+	// Not allowed origin
+	chain = newCORSChain(NewCORSFilter("https://example.com"))
+	w = httptest.NewRecorder()
+	r.Header.Set("Origin", "https://other.com")
+	chain.ServeHTTP(w, r)
+	if w.Body.String() != "END" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unexpected response %v %v", w.Header(), w.Body.String())
+	}
+}
+
+func TestCORSFilterWildcardCredentials(t *testing.T) {
+	f := NewCORSFilter("https://example.com", "*")
+	f.AllowCredentials = true
+	chain := newCORSChain(f)
+
+	origins := map[string]string{
+		"https://example.com": "true",
+		"https://evil.com":    "",
+	}
+	for origin, credentials := range origins {
+		for _, preflight := range []bool{false, true} {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/", nil)
+			if preflight {
+				r.Method = "OPTIONS"
+				r.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			r.Header.Set("Origin", origin)
+			chain.ServeHTTP(w, r)
+			allowOrigin := origin
+			if credentials == "" {
+				allowOrigin = "*"
+			}
+			if w.Header().Get("Access-Control-Allow-Origin") != allowOrigin ||
+				w.Header().Get("Access-Control-Allow-Credentials") != credentials {
+				t.Fatalf("unexpected headers for %s (preflight %v): %v", origin, preflight, w.Header())
+			}
+		}
+	}
+}
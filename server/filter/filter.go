@@ -5,6 +5,8 @@ package filter
 
 import (
 	"net/http"
+
+	"github.com/goburrow/gomelon/core"
 )
 
 const (
@@ -36,6 +38,7 @@ func NewChain() *Chain {
 // ServeHTTP starts the filter chain.
 func (chain *Chain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if len(chain.filters) > 0 {
+		core.AddInternalMetric("filters.requests", 1)
 		chain.filters[0].ServeHTTP(w, r, chain.filters[1:])
 	}
 }
@@ -47,17 +50,26 @@ func (chain *Chain) Add(f Filter) {
 
 // Insert inserts the filter before the filter with the given name.
 func (chain *Chain) Insert(f Filter, name string) {
-	idx := -1
-	for i, filter := range chain.filters {
+	chain.insert(f, chain.index(name))
+}
+
+// Contains returns true if the chain has a filter with the given name.
+func (chain *Chain) Contains(name string) bool {
+	for _, filter := range chain.filters {
 		if filter.Name() == name {
-			idx = i
-			break
+			return true
 		}
 	}
-	if idx < 0 {
-		panic("filter: name not found " + name)
+	return false
+}
+
+func (chain *Chain) index(name string) int {
+	for i, filter := range chain.filters {
+		if filter.Name() == name {
+			return i
+		}
 	}
-	chain.insert(f, idx)
+	panic("filter: name not found " + name)
 }
 
 func (chain *Chain) insert(f Filter, idx int) {
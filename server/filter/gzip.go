@@ -0,0 +1,251 @@
+package filter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	gzipFilterName = "gzip"
+
+	defaultGzipMinimumSize = 256
+)
+
+// defaultGzipExcludedTypes are prefixes of content types which are already
+// compressed.
+var defaultGzipExcludedTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+}
+
+// GzipFactory is the configuration of response compression.
+type GzipFactory struct {
+	// Enabled adds gzip filter to the filter chain.
+	Enabled bool
+	// MinimumSize is the minimum size of response body in bytes to be
+	// compressed. Default is 256.
+	MinimumSize int
+	// ExcludedContentTypes are prefixes of content types which are not
+	// compressed. Images, video, audio and archives are excluded by default.
+	ExcludedContentTypes []string
+}
+
+// Build creates a new GzipFilter from the configuration.
+func (f *GzipFactory) Build() *GzipFilter {
+	filter := NewGzipFilter()
+	if f.MinimumSize > 0 {
+		filter.MinimumSize = f.MinimumSize
+	}
+	if len(f.ExcludedContentTypes) > 0 {
+		filter.ExcludedContentTypes = f.ExcludedContentTypes
+	}
+	return filter
+}
+
+// GzipFilter compresses response body when the client accepts gzip encoding.
+type GzipFilter struct {
+	MinimumSize          int
+	ExcludedContentTypes []string
+}
+
+var _ Filter = (*GzipFilter)(nil)
+
+// NewGzipFilter allocates and returns a new GzipFilter with default settings.
+func NewGzipFilter() *GzipFilter {
+	return &GzipFilter{
+		MinimumSize:          defaultGzipMinimumSize,
+		ExcludedContentTypes: defaultGzipExcludedTypes,
+	}
+}
+
+func (f *GzipFilter) Name() string {
+	return gzipFilterName
+}
+
+func (f *GzipFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	if r.Method == "HEAD" || !acceptsGzip(r) {
+		chain[0].ServeHTTP(w, r, chain[1:])
+		return
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	gw := &gzipResponseWriter{writer: w, filter: f}
+	chain[0].ServeHTTP(gw, r, chain[1:])
+	// Not deferred so that nothing is written when the handler panics and
+	// the error response can still be sent by previous filters.
+	gw.close()
+}
+
+// isExcluded returns true if the content type should not be compressed.
+func (f *GzipFilter) isExcluded(contentType string) bool {
+	for _, t := range f.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip returns true if gzip is in the Accept-Encoding header and not
+// disabled with q=0.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header["Accept-Encoding"] {
+		for _, encoding := range strings.Split(v, ",") {
+			params := strings.Split(encoding, ";")
+			if strings.TrimSpace(params[0]) != "gzip" {
+				continue
+			}
+			for _, p := range params[1:] {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, "q=") {
+					q, err := strconv.ParseFloat(p[2:], 64)
+					return err == nil && q > 0
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers response body until it reaches the minimum size
+// and then decides whether the response is compressed.
+type gzipResponseWriter struct {
+	writer http.ResponseWriter
+	filter *GzipFilter
+
+	status   int
+	buf      []byte
+	decided  bool
+	gz       *gzip.Writer
+	hijacked bool
+}
+
+var (
+	_ http.Flusher  = (*gzipResponseWriter)(nil)
+	_ http.Hijacker = (*gzipResponseWriter)(nil)
+)
+
+func (w *gzipResponseWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.filter.MinimumSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.writer.Write(b)
+}
+
+// Flush sends buffered data to the client.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the handler take over the connection, e.g. for websockets.
+// Nothing is written to the response afterwards.
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("filter: http.Hijacker is not implemented")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// decide writes header and buffered data either compressed or as is.
+func (w *gzipResponseWriter) decide() error {
+	w.decided = true
+	header := w.writer.Header()
+	if w.shouldCompress() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		w.gz = gzip.NewWriter(w.writer)
+	}
+	if w.status != 0 {
+		w.writer.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf)
+	} else {
+		_, err = w.writer.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+func (w *gzipResponseWriter) shouldCompress() bool {
+	if len(w.buf) < w.filter.MinimumSize {
+		return false
+	}
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	header := w.writer.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		// Set it here as it can not be detected from compressed data.
+		contentType = http.DetectContentType(w.buf)
+		header.Set("Content-Type", contentType)
+	}
+	return !w.filter.isExcluded(contentType)
+}
+
+// close writes remaining data and finishes compression.
+func (w *gzipResponseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipFilter(t *testing.T) {
+	text := strings.Repeat("gomelon ", 100)
+	tests := []struct {
+		acceptEncoding string
+		contentType    string
+		body           string
+		compressed     bool
+	}{
+		{"gzip, deflate", "", text, true},
+		{"deflate", "", text, false},
+		{"gzip;q=0", "", text, false},
+		{"gzip;q=0.5", "text/plain", text, true},
+		{"gzip", "", "small", false},
+		{"gzip", "image/png", text, false},
+	}
+	for _, test := range tests {
+		builder := NewChain()
+		builder.Add(NewGzipFilter())
+		chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.contentType != "" {
+				w.Header().Set("Content-Type", test.contentType)
+			}
+			w.WriteHeader(http.StatusCreated)
+			// Multiple writes
+			w.Write([]byte(test.body[:len(test.body)/2]))
+			w.Write([]byte(test.body[len(test.body)/2:]))
+		}))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		chain.ServeHTTP(w, r)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("unexpected code %v for %+v", w.Code, test)
+		}
+		if !test.compressed {
+			if w.Header().Get("Content-Encoding") != "" || w.Body.String() != test.body {
+				t.Fatalf("unexpected response %v %v for %+v", w.Header(), w.Body.String(), test)
+			}
+			continue
+		}
+		if w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("unexpected headers %v for %+v", w.Header(), test)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+			t.Fatalf("unexpected content type %v", w.Header().Get("Content-Type"))
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != test.body {
+			t.Fatalf("unexpected body %s", body)
+		}
+	}
+}
+
+func TestGzipFactory(t *testing.T) {
+	factory := GzipFactory{MinimumSize: 1024, ExcludedContentTypes: []string{"application/json"}}
+	f := factory.Build()
+	if f.MinimumSize != 1024 || !f.isExcluded("application/json; charset=utf-8") || f.isExcluded("image/png") {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+}
+
+func TestGzipFilterPanic(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewGzipFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("gomelon ", 10)))
+		panic("panic")
+	}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	func() {
+		defer func() {
+			if p := recover(); p != "panic" {
+				t.Fatalf("unexpected panic %v", p)
+			}
+		}()
+		chain.ServeHTTP(w, r)
+	}()
+	// Error response can still be written.
+	if w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("unexpected response %v %q", w.Header(), w.Body.String())
+	}
+}
+
+func TestGzipFilterHijack(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewGzipFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\nhijacked")
+		buf.Flush()
+	}))
+	server := httptest.NewServer(chain)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nAccept-Encoding: gzip\r\n\r\n"))
+	b, _ := ioutil.ReadAll(conn)
+	if string(b) != "HTTP/1.1 101 Switching Protocols\r\n\r\nhijacked" {
+		t.Fatalf("unexpected response %q", b)
+	}
+}
@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIPNets parses IP addresses and CIDR blocks, e.g. "10.0.0.1" or
+// "10.0.0.0/8".
+func ParseIPNets(values []string) ([]*net.IPNet, error) {
+	var ipNets []*net.IPNet
+	for _, s := range values {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("filter: invalid IP address %s", s)
+			}
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid IP address %v", err)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
+
+// ContainsIP returns true if ip is in one of ipNets.
+func ContainsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/gomelon/core"
+	"golang.org/x/net/context"
+)
+
+const (
+	metricsFilterName = "metrics"
+	traceParentHeader = "Traceparent"
+
+	metricsPrefix    = "HTTP.Routes."
+	unmatchedRoute   = "Unmatched"
+	latencyMaxMillis = 1000 * 60 * 3
+)
+
+// latencyBuckets are upper bounds in seconds of route latency histograms.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsFilter records number of requests, latency and response status
+// codes for each route. Route is the method and the pattern matched by the
+// router (see SetRoute), so paths with parameters share the same metrics.
+// When exemplars are enabled (see core.EnableExemplars), latency in seconds
+// is also recorded in a bucket histogram of the route, e.g.
+// "HTTP.Routes.GET /users/:id.Latency.Seconds", and requests with a W3C
+// traceparent header are recorded as exemplars of its buckets.
+type MetricsFilter struct {
+	mu     sync.Mutex
+	routes map[string]*routeMetrics
+}
+
+var _ Filter = (*MetricsFilter)(nil)
+
+// NewMetricsFilter allocates and returns a new MetricsFilter.
+func NewMetricsFilter() *MetricsFilter {
+	return &MetricsFilter{
+		routes: make(map[string]*routeMetrics),
+	}
+}
+
+func (f *MetricsFilter) Name() string {
+	return metricsFilterName
+}
+
+func (f *MetricsFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	route := new(string)
+	r = r.WithContext(context.WithValue(r.Context(), routeKey, route))
+	rw := NewResponseWriter(w)
+	chain[0].ServeHTTP(rw, r, chain[1:])
+
+	name := *route
+	if name == "" {
+		name = unmatchedRoute
+	}
+	f.getRoute(name).record(rw.Status(), rw.Duration(), r.Header.Get(traceParentHeader))
+}
+
+func (f *MetricsFilter) getRoute(name string) *routeMetrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.routes[name]
+	if !ok {
+		m = newRouteMetrics(metricsPrefix + name)
+		f.routes[name] = m
+	}
+	return m
+}
+
+// SetRoute sets the route of the request in the context if it is being
+// tracked by MetricsFilter. It is called by the router after matching.
+func SetRoute(ctx context.Context, route string) {
+	if p, ok := ctx.Value(routeKey).(*string); ok {
+		*p = route
+	}
+}
+
+type routeMetrics struct {
+	prefix   string
+	requests metrics.Counter
+	latency  *metrics.Histogram
+}
+
+func newRouteMetrics(prefix string) *routeMetrics {
+	return &routeMetrics{
+		prefix:   prefix,
+		requests: metrics.Counter(prefix + ".Requests"),
+		latency:  metrics.NewHistogram(prefix+".Latency", 1, latencyMaxMillis, 3),
+	}
+}
+
+func (m *routeMetrics) record(status int, elapsed time.Duration, traceParent string) {
+	m.requests.Add()
+	metrics.Counter(m.prefix + ".Responses." + strconv.Itoa(status)).Add()
+	_ = m.latency.RecordValue(int64(elapsed.Seconds() * 1000))
+	if !core.ExemplarsEnabled() {
+		return
+	}
+	var exemplar *core.Exemplar
+	if traceID := parseTraceID(traceParent); traceID != "" {
+		exemplar = &core.Exemplar{
+			TraceID: traceID,
+			Value:   elapsed.Seconds(),
+			Time:    time.Now(),
+		}
+	}
+	core.Histogram(m.prefix+".Latency.Seconds", latencyBuckets).Observe(elapsed.Seconds(), exemplar)
+}
+
+// parseTraceID returns trace ID of the W3C traceparent header value, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", or an empty
+// string if it is not valid.
+func parseTraceID(traceParent string) string {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ""
+	}
+	traceID := parts[1]
+	if strings.Trim(traceID, "0") == "" {
+		return ""
+	}
+	for i := 0; i < len(traceID); i++ {
+		c := traceID[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return ""
+		}
+	}
+	return traceID
+}
@@ -0,0 +1,87 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codahale/metrics"
+	"github.com/goburrow/gomelon/core"
+)
+
+func TestMetricsFilter(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewMetricsFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notfound" {
+			http.NotFound(w, r)
+			return
+		}
+		SetRoute(r.Context(), "GET /users/:id")
+		if r.URL.Path == "/users/0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	for _, path := range []string{"/users/1", "/users/2", "/users/0", "/notfound"} {
+		r, _ := http.NewRequest("GET", path, nil)
+		chain.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	counters, _ := metrics.Snapshot()
+	expected := map[string]uint64{
+		"HTTP.Routes.GET /users/:id.Requests":      3,
+		"HTTP.Routes.GET /users/:id.Responses.200": 2,
+		"HTTP.Routes.GET /users/:id.Responses.404": 1,
+		"HTTP.Routes.Unmatched.Requests":           1,
+		"HTTP.Routes.Unmatched.Responses.404":      1,
+	}
+	for k, v := range expected {
+		if counters[k] != v {
+			t.Fatalf("unexpected counter %s: %v, expected %v", k, counters[k], v)
+		}
+	}
+}
+
+func TestMetricsFilterExemplar(t *testing.T) {
+	core.EnableExemplars(true)
+	defer core.EnableExemplars(false)
+	builder := NewChain()
+	builder.Add(NewMetricsFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r.Context(), "GET /traced")
+	}))
+	r, _ := http.NewRequest("GET", "/traced", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	name := metricsPrefix + "GET /traced.Latency.Seconds"
+	defer core.RemoveHistogram(name)
+	histogram, ok := core.Histograms()[name]
+	if !ok || histogram.Count != 1 {
+		t.Fatalf("unexpected histogram %+v", histogram)
+	}
+	// Latency is in the first bucket.
+	exemplar := histogram.Exemplars[0]
+	if exemplar == nil || exemplar.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		exemplar.Value > latencyBuckets[0] {
+		t.Fatalf("unexpected exemplar %+v", exemplar)
+	}
+	if _, ok := core.Exemplars()[metricsPrefix+"GET /traced.Requests"]; ok {
+		t.Fatal("unexpected exemplar of requests counter")
+	}
+}
+
+func TestParseTraceID(t *testing.T) {
+	data := map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": "",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01": "",
+		"00-4bf92f3577b34da6-00f067aa0ba902b7-01":                 "",
+		"": "",
+	}
+	for traceParent, expected := range data {
+		if actual := parseTraceID(traceParent); actual != expected {
+			t.Fatalf("unexpected trace ID %q for %q", actual, traceParent)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package filter
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitFilterName = "ratelimit"
+
+	defaultRateLimitMaxClients = 10000
+)
+
+// RateLimitFactory is the configuration of request rate limiting.
+type RateLimitFactory struct {
+	// Enabled adds rate limit filter to the filter chain.
+	Enabled bool
+	// Rate is the number of requests per second allowed for each client.
+	Rate float64
+	// Burst is the maximum number of requests a client can make at once.
+	// Default is the rate.
+	Burst int
+	// Whitelist contains IP addresses or CIDR blocks which are not limited.
+	Whitelist []string
+	// MaxClients is the maximum number of clients tracked. The least
+	// recently seen clients are removed when it is reached. Default is 10000.
+	MaxClients int
+}
+
+// Build creates a new RateLimitFilter.
+func (f *RateLimitFactory) Build() (*RateLimitFilter, error) {
+	if f.Rate <= 0 {
+		return nil, fmt.Errorf("filter: invalid rate limit %v", f.Rate)
+	}
+	filter := NewRateLimitFilter(f.Rate, f.Burst)
+	if f.MaxClients > 0 {
+		filter.maxClients = f.MaxClients
+	}
+	var err error
+	if filter.whitelist, err = ParseIPNets(f.Whitelist); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// RateLimitFilter limits request rate of each client IP using token bucket.
+// Client IP is the remote address of the request, which is restored from
+// X-Forwarded-For header by the proxy filter for trusted proxies.
+type RateLimitFilter struct {
+	rate       float64
+	burst      float64
+	maxClients int
+	whitelist  []*net.IPNet
+	// For testing
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// lru contains buckets ordered by last access, most recent first.
+	lru *list.List
+}
+
+var _ Filter = (*RateLimitFilter)(nil)
+
+// NewRateLimitFilter allocates and returns a new RateLimitFilter allowing
+// rate requests per second and burst requests at once for each client.
+func NewRateLimitFilter(rate float64, burst int) *RateLimitFilter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rate))
+	}
+	return &RateLimitFilter{
+		rate:       rate,
+		burst:      float64(burst),
+		maxClients: defaultRateLimitMaxClients,
+		now:        time.Now,
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (f *RateLimitFilter) Name() string {
+	return rateLimitFilterName
+}
+
+func (f *RateLimitFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	ip := f.clientIP(r)
+	if ip != nil && !ContainsIP(f.whitelist, ip) {
+		if wait := f.take(ip.String()); wait > 0 {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Too many requests.", http.StatusTooManyRequests)
+			return
+		}
+	}
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// clientIP returns the IP address of the remote address.
+func (f *RateLimitFilter) clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// tokenBucket is the state of a client.
+type tokenBucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// take consumes a token from the bucket of the given client. It returns
+// the duration to wait for the next token if the bucket is empty.
+func (f *RateLimitFilter) take(key string) time.Duration {
+	now := f.now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var bucket *tokenBucket
+	if e, ok := f.buckets[key]; ok {
+		f.lru.MoveToFront(e)
+		bucket = e.Value.(*tokenBucket)
+		bucket.tokens += now.Sub(bucket.last).Seconds() * f.rate
+		if bucket.tokens > f.burst {
+			bucket.tokens = f.burst
+		}
+		bucket.last = now
+	} else {
+		if f.lru.Len() >= f.maxClients {
+			oldest := f.lru.Back()
+			f.lru.Remove(oldest)
+			delete(f.buckets, oldest.Value.(*tokenBucket).key)
+		}
+		bucket = &tokenBucket{key: key, tokens: f.burst, last: now}
+		f.buckets[key] = f.lru.PushFront(bucket)
+	}
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / f.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return 0
+}
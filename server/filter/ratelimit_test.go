@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitFilter(t *testing.T) {
+	factory := RateLimitFactory{
+		Rate:      0.5,
+		Burst:     2,
+		Whitelist: []string{"10.0.0.0/8"},
+	}
+	f, err := factory.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := time.Date(2015, time.January, 14, 1, 2, 3, 0, time.UTC)
+	f.now = func() time.Time { return current }
+	builder := NewChain()
+	builder.Add(f)
+	chain := builder.Build(http.HandlerFunc(end))
+
+	request := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		chain.ServeHTTP(w, r)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := request("127.0.0.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("unexpected code %v", w.Code)
+		}
+	}
+	w := request("127.0.0.1:1234", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Header())
+	}
+	// Other clients
+	if w = request("127.0.0.2:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w = request("10.0.0.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("unexpected code %v", w.Code)
+		}
+	}
+	// Header is ignored as the proxy filter restores the remote address.
+	if w = request("127.0.0.1:1234", "127.0.0.3"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w = request("192.168.0.1:1234", "127.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	// Refilled
+	current = current.Add(2 * time.Second)
+	if w = request("127.0.0.1:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w = request("127.0.0.1:1234", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestRateLimitFilterMaxClients(t *testing.T) {
+	f := NewRateLimitFilter(1, 1)
+	f.maxClients = 2
+	for _, key := range []string{"a", "b", "a", "c"} {
+		f.take(key)
+	}
+	if len(f.buckets) != 2 || f.lru.Len() != 2 {
+		t.Fatalf("unexpected buckets %v", f.buckets)
+	}
+	if _, ok := f.buckets["b"]; ok {
+		t.Fatalf("least recently used bucket is not removed %v", f.buckets)
+	}
+}
+
+func TestRateLimitFactoryInvalid(t *testing.T) {
+	factory := RateLimitFactory{Rate: 1, Whitelist: []string{"localhost"}}
+	if _, err := factory.Build(); err == nil {
+		t.Fatal("error expected")
+	}
+	factory = RateLimitFactory{}
+	if _, err := factory.Build(); err == nil {
+		t.Fatal("error expected")
+	}
+}
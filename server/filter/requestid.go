@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	requestIDFilterName = "requestid"
+	requestIDHeader     = "X-Request-Id"
+	requestIDMaxLength  = 128
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	routeKey
+)
+
+var (
+	// requestIDPrefix is generated randomly for each process.
+	requestIDPrefix [8]byte
+	requestIDSeq    uint64
+)
+
+func init() {
+	if _, err := rand.Read(requestIDPrefix[:]); err != nil {
+		panic("filter: could not generate request id prefix: " + err.Error())
+	}
+}
+
+// RequestIDFilter sets request ID from X-Request-Id header, or a newly
+// generated one if absent, to the request context and response header.
+type RequestIDFilter struct {
+}
+
+var _ Filter = (*RequestIDFilter)(nil)
+
+// NewRequestIDFilter allocates and returns a new RequestIDFilter.
+func NewRequestIDFilter() *RequestIDFilter {
+	return &RequestIDFilter{}
+}
+
+func (f *RequestIDFilter) Name() string {
+	return requestIDFilterName
+}
+
+func (f *RequestIDFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	id := r.Header.Get(requestIDHeader)
+	if !isValidRequestID(id) {
+		id = newRequestID()
+		// So the request log can record it.
+		r.Header.Set(requestIDHeader, id)
+	}
+	w.Header().Set(requestIDHeader, id)
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// RequestID returns the request ID stored in the context by RequestIDFilter.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// newRequestID returns an unique ID in UUID format which is composed of
+// random prefix of the process and a sequence number.
+func newRequestID() string {
+	var b [16]byte
+	copy(b[:8], requestIDPrefix[:])
+	binary.BigEndian.PutUint64(b[8:], atomic.AddUint64(&requestIDSeq, 1))
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+// isValidRequestID prevents clients from injecting arbitrary data into logs.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIDFilter(t *testing.T) {
+	var id string
+	builder := NewChain()
+	builder.Add(NewRequestIDFilter())
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "upstream-id")
+	chain.ServeHTTP(w, r)
+	if id != "upstream-id" || w.Header().Get("X-Request-Id") != "upstream-id" {
+		t.Fatalf("unexpected request id %v %v", id, w.Header())
+	}
+
+	ids := make(map[string]bool)
+	for _, header := range []string{"", "", "invalid\nid"} {
+		w = httptest.NewRecorder()
+		r, _ = http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("X-Request-Id", header)
+		}
+		chain.ServeHTTP(w, r)
+		if len(id) != 36 || w.Header().Get("X-Request-Id") != id || r.Header.Get("X-Request-Id") != id {
+			t.Fatalf("unexpected request id %v %v", id, w.Header())
+		}
+		if ids[id] {
+			t.Fatalf("duplicated request id %v", id)
+		}
+		ids[id] = true
+	}
+}
+
+func BenchmarkNewRequestID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newRequestID()
+	}
+}
@@ -0,0 +1,72 @@
+package filter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	timeoutFilterName = "timeout"
+	timeoutLoggerName = "gomelon/server/filter"
+)
+
+// TimeoutFilter responds 503 Service Unavailable when the next filters do
+// not complete within the timeout. The request context is cancelled so that
+// handlers can stop early by checking ctx.Done(). Responses are passed
+// through so they can be streamed, but a response which has been started can
+// not be replaced after the timeout.
+type TimeoutFilter struct {
+	timeout time.Duration
+}
+
+var _ Filter = (*TimeoutFilter)(nil)
+
+// NewTimeoutFilter allocates and returns a new TimeoutFilter.
+func NewTimeoutFilter(timeout time.Duration) *TimeoutFilter {
+	return &TimeoutFilter{timeout: timeout}
+}
+
+func (f *TimeoutFilter) Name() string {
+	return timeoutFilterName
+}
+
+func (f *TimeoutFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []Filter) {
+	ctx, cancel := context.WithTimeout(r.Context(), f.timeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := util.NewTimeoutWriter(w)
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			// Panic is propagated to the caller's goroutine so it can be
+			// recovered by previous filters.
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		chain[0].ServeHTTP(tw, r, chain[1:])
+		close(done)
+	}()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+	case <-ctx.Done():
+		started := !tw.TimeOut()
+		if ctx.Err() != context.DeadlineExceeded {
+			// Client has gone away.
+			return
+		}
+		gol.GetLogger(timeoutLoggerName).Warn("%s %s timed out after %v",
+			r.Method, r.URL.Path, f.timeout)
+		if !started {
+			http.Error(w, "Request timed out.", http.StatusServiceUnavailable)
+		}
+	}
+}
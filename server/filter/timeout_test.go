@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestTimeoutFilter(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewTimeoutFilter(50 * time.Millisecond))
+	cancelled := make(chan struct{})
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast" {
+			w.Header().Set("X-Test", "1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("fast"))
+			return
+		}
+		<-r.Context().Done()
+		close(cancelled)
+		// Written after timeout
+		w.Write([]byte("slow"))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/fast", nil)
+	chain.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated || w.Body.String() != "fast" || w.Header().Get("X-Test") != "1" {
+		t.Fatalf("unexpected response %v %v %v", w.Code, w.Header(), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/slow", nil)
+	chain.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "Request timed out.\n" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context is not cancelled")
+	}
+}
+
+func TestTimeoutFilterPanic(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewTimeoutFilter(time.Second))
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("panic")
+	}))
+	defer func() {
+		if p := recover(); p != "panic" {
+			t.Fatalf("unexpected panic %v", p)
+		}
+	}()
+	r, _ := http.NewRequest("GET", "/", nil)
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestTimeoutFilterFlush(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewTimeoutFilter(50 * time.Millisecond))
+	flushed := make(chan struct{})
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("event"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-r.Context().Done()
+	}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	chain.ServeHTTP(w, r)
+	select {
+	case <-flushed:
+	default:
+		t.Fatal("response is not flushed")
+	}
+	// Response has been started so it is not replaced.
+	if w.Code != http.StatusOK || !w.Flushed || w.Body.String() != "event" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutFilterCanceled(t *testing.T) {
+	builder := NewChain()
+	builder.Add(NewTimeoutFilter(time.Second))
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	chain.ServeHTTP(w, r.WithContext(ctx))
+	if w.Body.Len() != 0 {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter wraps http.ResponseWriter to record response status and
+// number of bytes written. Flush, Hijack and ReadFrom are passed through to
+// the underlying writer if it supports them.
+type ResponseWriter struct {
+	writer http.ResponseWriter
+	status int
+	size   int64
+	start  time.Time
+}
+
+var (
+	_ http.Flusher  = (*ResponseWriter)(nil)
+	_ http.Hijacker = (*ResponseWriter)(nil)
+	_ io.ReaderFrom = (*ResponseWriter)(nil)
+)
+
+// NewResponseWriter allocates and returns a new ResponseWriter started at
+// the current time.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{
+		writer: w,
+		start:  time.Now(),
+	}
+}
+
+// Status returns the response status code, which is 200 OK if it has not
+// been written explicitly.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of bytes of the response body written.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Start returns the time the writer was created.
+func (w *ResponseWriter) Start() time.Time {
+	return w.start
+}
+
+// Duration returns the time elapsed since the writer was created.
+func (w *ResponseWriter) Duration() time.Duration {
+	return time.Since(w.start)
+}
+
+func (w *ResponseWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.writer.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// WriteString writes s without copying it if the underlying writer supports.
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.WriteString(w.writer, s)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.writer.WriteHeader(status)
+}
+
+// ReadFrom allows the underlying writer to use sendfile.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.writer.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.writer}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+func (w *ResponseWriter) Flush() {
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.writer.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("filter: http.Hijacker is not implemented")
+}
+
+// writerOnly hides ReadFrom of the writer to avoid recursion in io.Copy.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriterFlush(t *testing.T) {
+	var rw *ResponseWriter
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw = NewResponseWriter(w)
+		rw.Write([]byte("hello"))
+		rw.Flush()
+		rw.WriteString(" world")
+		rw.ReadFrom(strings.NewReader("!"))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello world!" {
+		t.Fatalf("unexpected body %q", b)
+	}
+	// Flushed response is chunked.
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("unexpected transfer encoding %v", resp.TransferEncoding)
+	}
+	if rw.Status() != http.StatusOK || rw.Size() != 12 || rw.Duration() <= 0 {
+		t.Fatalf("unexpected writer %v %v %v", rw.Status(), rw.Size(), rw.Duration())
+	}
+}
+
+func TestResponseWriterHijack(t *testing.T) {
+	var rw *ResponseWriter
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw = NewResponseWriter(w)
+		conn, buf, err := rw.Hijack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\nhijacked")
+		buf.Flush()
+	}))
+	defer server.Close()
+
+	conn, err := (&net.Dialer{}).Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "HTTP/1.1 101 Switching Protocols\r\n" {
+		t.Fatalf("unexpected response %q %v", line, err)
+	}
+	reader.ReadString('\n')
+	b, _ := ioutil.ReadAll(reader)
+	if string(b) != "hijacked" {
+		t.Fatalf("unexpected body %q", b)
+	}
+
+	// Not supported
+	w := NewResponseWriter(&struct{ http.ResponseWriter }{httptest.NewRecorder()})
+	if _, _, err = w.Hijack(); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestResponseWriterStatus(t *testing.T) {
+	w := NewResponseWriter(httptest.NewRecorder())
+	if w.Status() != http.StatusOK {
+		t.Fatalf("unexpected status %v", w.Status())
+	}
+	w.WriteHeader(http.StatusNotFound)
+	w.WriteHeader(http.StatusInternalServerError)
+	if w.Status() != http.StatusNotFound {
+		t.Fatalf("unexpected status %v", w.Status())
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/goburrow/health"
+)
+
+const (
+	connectorsHealthCheckName = "connectors"
+	connectorDialTimeout      = 1 * time.Second
+)
+
+// connectorsHealthCheck checks if all connectors are accepting connections.
+// It fails if any connector has stopped serving, even when the others keep
+// the server running.
+type connectorsHealthCheck struct {
+	connectors []*Connector
+}
+
+var _ health.HealthCheck = (*connectorsHealthCheck)(nil)
+
+func (c *connectorsHealthCheck) Check() health.Result {
+	var failures []string
+	var cause error
+	for _, connector := range c.connectors {
+		if err := connector.failure(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s failed: %v", connector.Type, connector.Addr, err))
+			cause = err
+			continue
+		}
+		addr := connector.LocalAddr()
+		if addr == nil {
+			failures = append(failures, fmt.Sprintf("%s %s is not listening", connector.Type, connector.Addr))
+			continue
+		}
+		conn, err := net.DialTimeout(addr.Network(), addr.String(), connectorDialTimeout)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s is not accepting connections", connector.Type, connector.Addr))
+			cause = err
+			continue
+		}
+		conn.Close()
+	}
+	if len(failures) > 0 {
+		return health.ResultUnhealthy(strings.Join(failures, "; "), cause)
+	}
+	return health.Healthy
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+func TestConnectorsHealthCheck(t *testing.T) {
+	connectors := newTestConnectors(2)
+	for _, c := range connectors {
+		if err := c.open(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.close()
+	}
+	check := &connectorsHealthCheck{connectors: connectors}
+	result := check.Check()
+	if !result.Healthy() {
+		t.Fatalf("unexpected result %v", result.Message())
+	}
+	// Listening but not accepting
+	connectors[1].close()
+	result = check.Check()
+	if result.Healthy() {
+		t.Fatal("unhealthy result expected")
+	}
+	if !strings.Contains(result.Message(), "http 127.0.0.1:0") {
+		t.Fatalf("unexpected message %v", result.Message())
+	}
+}
+
+func TestDisableDefaultHealthCheck(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &commonFactory{DisableDefaultHealthCheck: true}
+	if err := factory.configureAdmin(env, NewServer()); err != nil {
+		t.Fatal(err)
+	}
+	if names := env.Admin.HealthChecks.Names(); len(names) != 0 {
+		t.Fatalf("unexpected health checks %v", names)
+	}
+}
+
+func TestConnectorsHealthCheckFailure(t *testing.T) {
+	connectors := newTestConnectors(2)
+	for _, c := range connectors {
+		if err := c.open(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.close()
+	}
+	// Second connector stops serving while the first one is still running.
+	connectors[1].close()
+	if err := connectors[1].serve(); err == nil {
+		t.Fatal("error expected")
+	}
+	check := &connectorsHealthCheck{connectors: connectors}
+	result := check.Check()
+	if result.Healthy() {
+		t.Fatal("unhealthy result expected")
+	}
+	if !strings.Contains(result.Message(), "http 127.0.0.1:0 failed") || result.Cause() == nil {
+		t.Fatalf("unexpected result %v %v", result.Message(), result.Cause())
+	}
+}
+
+func TestConnectorsHealthCheckRegistered(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &DefaultFactory{
+		ApplicationConnectors: []Connector{Connector{Type: "http", Addr: "127.0.0.1:0"}},
+	}
+	if _, err := factory.Build(env); err != nil {
+		t.Fatal(err)
+	}
+	if !hasHealthCheck(env, connectorsHealthCheckName) {
+		t.Fatalf("unexpected health checks %v", env.Admin.HealthChecks.Names())
+	}
+
+	env = core.NewEnvironment()
+	disabled := false
+	factory.ConnectorsHealthCheck = &disabled
+	if _, err := factory.Build(env); err != nil {
+		t.Fatal(err)
+	}
+	if hasHealthCheck(env, connectorsHealthCheckName) {
+		t.Fatalf("unexpected health checks %v", env.Admin.HealthChecks.Names())
+	}
+}
+
+func hasHealthCheck(env *core.Environment, name string) bool {
+	for _, n := range env.Admin.HealthChecks.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
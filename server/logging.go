@@ -3,8 +3,10 @@ package server
 import (
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/goburrow/gol/file/rotation"
 	"github.com/goburrow/gomelon/core"
@@ -16,6 +18,7 @@ import (
 
 const (
 	requestLogBufferSize = 1024
+	requestLogTaskName   = "requestlog"
 )
 
 // RequestLogFactory builds logging filter.
@@ -23,19 +26,59 @@ type RequestLogFactory interface {
 	Build(*core.Environment) (filter.Filter, error)
 }
 
+// Request log formats.
+const (
+	requestLogFormatCommon   = ""
+	requestLogFormatJSON     = "json"
+	requestLogFormatCombined = "combined"
+)
+
 // DefaultRequestLogFactory is the configuration for the default request log
 // factory. It utilized the configuration of logging appenders.
 type DefaultRequestLogFactory struct {
 	// TODO: Eliminate logging dependency
 	Appenders []logging.AppenderConfiguration
+	// Format is the layout of log entries: empty for the default format,
+	// "json" or "combined" for NCSA combined log format.
+	Format string
+	// BufferSize is the number of log entries queued for each appender.
+	BufferSize int
+	// DiscardWhenFull drops log entries instead of blocking requests when
+	// the buffer is full.
+	DiscardWhenFull bool
 }
 
 var _ RequestLogFactory = (*DefaultRequestLogFactory)(nil)
 
 func (f *DefaultRequestLogFactory) Build(env *core.Environment) (filter.Filter, error) {
+	var newFilter func(io.Writer) filter.Filter
+	switch f.Format {
+	case requestLogFormatCommon:
+		newFilter = func(w io.Writer) filter.Filter { return slogging.NewFilter(w) }
+	case requestLogFormatJSON:
+		newFilter = func(w io.Writer) filter.Filter { return slogging.NewJSONFilter(w) }
+	case requestLogFormatCombined:
+		newFilter = func(w io.Writer) filter.Filter { return slogging.NewCombinedFilter(w) }
+	default:
+		return nil, fmt.Errorf("server: unsupported request log format %q", f.Format)
+	}
+	writer, err := buildRequestLogWriter(env, f.Appenders, f.BufferSize, f.DiscardWhenFull)
+	if err != nil {
+		return nil, err
+	}
+	if writer == nil {
+		return &noRequestLog{}, nil
+	}
+	return newFilter(writer), nil
+}
+
+// buildRequestLogWriter creates an asynchronous writer for the given appenders.
+// It returns nil if there is no appender.
+func buildRequestLogWriter(env *core.Environment, appenders []logging.AppenderConfiguration,
+	bufferSize int, discardWhenFull bool) (io.Writer, error) {
 	var writers []io.Writer
 
-	for _, appender := range f.Appenders {
+	for _, appender := range appenders {
 		switch appenderFactory := appender.Value().(type) {
 		case *logging.ConsoleAppenderFactory:
 			w, err := buildConsoleWriter(appenderFactory)
@@ -55,11 +98,52 @@ func (f *DefaultRequestLogFactory) Build(env *core.Environment) (filter.Filter,
 	}
 	if len(writers) == 0 {
 		// No request log
-		return &noRequestLog{}, nil
+		return nil, nil
+	}
+	if bufferSize <= 0 {
+		bufferSize = requestLogBufferSize
 	}
-	asyncWriter := util.NewAsyncWriter(requestLogBufferSize, writers...)
-	env.Lifecycle.Manage(asyncWriter)
-	return slogging.NewFilter(asyncWriter), nil
+	asyncWriter := util.NewAsyncWriter(bufferSize, writers...)
+	asyncWriter.DiscardWhenFull = discardWhenFull
+	writer := &requestLogWriter{
+		AsyncWriter: asyncWriter,
+		writers:     writers,
+		lifecycle:   env.Lifecycle,
+	}
+	env.Lifecycle.Manage(writer)
+	return writer, nil
+}
+
+// requestLogWriter closes file writers when the async writer is stopped, e.g.
+// when the request log is reloaded.
+type requestLogWriter struct {
+	*util.AsyncWriter
+	writers   []io.Writer
+	lifecycle *core.LifecycleEnvironment
+
+	stopOnce sync.Once
+}
+
+// Stop stops the async writer and closes files. It can be called more than
+// once as the writer is also stopped by the lifecycle.
+func (w *requestLogWriter) Stop() error {
+	w.stopOnce.Do(func() {
+		w.AsyncWriter.Stop()
+		for _, writer := range w.writers {
+			if f, ok := writer.(io.Closer); ok && writer != os.Stdout && writer != os.Stderr {
+				f.Close()
+			}
+		}
+	})
+	return nil
+}
+
+// Close stops the writer and removes it from the lifecycle as it has been
+// replaced.
+func (w *requestLogWriter) Close() error {
+	err := w.Stop()
+	w.lifecycle.Unmanage(w)
+	return err
 }
 
 func buildConsoleWriter(config *logging.ConsoleAppenderFactory) (io.Writer, error) {
@@ -79,20 +163,32 @@ func buildFileWriter(config *logging.FileAppenderFactory) (io.Writer, error) {
 	if err := writer.Open(); err != nil {
 		return nil, err
 	}
-	if config.Archive {
-		triggeringPolicy := rotation.NewTimeTriggeringPolicy()
-		if err := triggeringPolicy.Start(); err != nil {
-			return nil, err
-		}
-		rollingPolicy := rotation.NewTimeRollingPolicy()
-		rollingPolicy.FilePattern = config.ArchivedLogFilenamePattern
-		rollingPolicy.FileCount = config.ArchivedFileCount
-
-		writer.SetTriggeringPolicy(triggeringPolicy)
-		writer.SetRollingPolicy(rollingPolicy)
-		// TODO: Close file
+	if !config.Archive {
+		return writer, nil
 	}
-	return writer, nil
+	triggeringPolicy := rotation.NewTimeTriggeringPolicy()
+	if err := triggeringPolicy.Start(); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	rollingPolicy := rotation.NewTimeRollingPolicy()
+	rollingPolicy.FilePattern = config.ArchivedLogFilenamePattern
+	rollingPolicy.FileCount = config.ArchivedFileCount
+
+	writer.SetTriggeringPolicy(triggeringPolicy)
+	writer.SetRollingPolicy(rollingPolicy)
+	return &archivedFileWriter{writer, triggeringPolicy}, nil
+}
+
+// archivedFileWriter stops the triggering policy when the file is closed.
+type archivedFileWriter struct {
+	*rotation.File
+	triggeringPolicy *rotation.TimeTriggeringPolicy
+}
+
+func (w *archivedFileWriter) Close() error {
+	w.triggeringPolicy.Stop()
+	return w.File.Close()
 }
 
 type noRequestLog struct{}
@@ -106,3 +202,106 @@ func (*noRequestLog) Name() string {
 func (*noRequestLog) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
 	chain[0].ServeHTTP(w, r, chain[1:])
 }
+
+// requestLogFilter allows replacing the request log filter while the server
+// is running.
+type requestLogFilter struct {
+	mu     sync.RWMutex
+	filter filter.Filter
+}
+
+var _ (filter.Filter) = (*requestLogFilter)(nil)
+
+func (f *requestLogFilter) Name() string {
+	return "logging"
+}
+
+func (f *requestLogFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	f.mu.RLock()
+	current := f.filter
+	f.mu.RUnlock()
+	current.ServeHTTP(w, r, chain)
+}
+
+// swap sets the new filter and returns the old one.
+func (f *requestLogFilter) swap(newFilter filter.Filter) filter.Filter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	oldFilter := f.filter
+	f.filter = newFilter
+	return oldFilter
+}
+
+// requestLogTask rebuilds request log filter from the configuration, e.g. to
+// reopen log files. A new request log configuration can be given in JSON
+// request body, e.g. {"type":"combined","appenders":[{"type":"ConsoleAppender"}]},
+// which is used for following reloads.
+type requestLogTask struct {
+	mu      sync.Mutex
+	factory *commonFactory
+	env     *core.Environment
+	filter  *requestLogFilter
+}
+
+func (*requestLogTask) Name() string {
+	return requestLogTaskName
+}
+
+func (task *requestLogTask) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	oldConfig := task.factory.RequestLog
+	newConfig := oldConfig
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		newConfig = RequestLogConfiguration{}
+		if err := core.DecodeTaskParams(r, &newConfig); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	newFilter, err := buildRequestLog(task.env, newConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task.factory.RequestLog = newConfig
+	oldFilter := task.filter.swap(newFilter)
+	// Requests still using the old filter may be dropped from the log.
+	if c, ok := oldFilter.(io.Closer); ok {
+		c.Close()
+	}
+	fmt.Fprintf(w, "Request log: %s -> %s\n", describeRequestLog(oldConfig), describeRequestLog(newConfig))
+}
+
+// describeRequestLog returns format and appenders of the request log, e.g.
+// "combined [console:stdout file:/var/log/access.log]".
+func describeRequestLog(config RequestLogConfiguration) string {
+	factory, ok := config.Value().(*DefaultRequestLogFactory)
+	if !ok {
+		if config.Value() == nil {
+			return "none"
+		}
+		return fmt.Sprintf("%T", config.Value())
+	}
+	format := factory.Format
+	if format == requestLogFormatCommon {
+		format = "common"
+	}
+	appenders := make([]string, 0, len(factory.Appenders))
+	for _, appender := range factory.Appenders {
+		switch a := appender.Value().(type) {
+		case *logging.ConsoleAppenderFactory:
+			target := a.Target
+			if target == "" {
+				target = "stdout"
+			}
+			appenders = append(appenders, "console:"+target)
+		case *logging.FileAppenderFactory:
+			appenders = append(appenders, "file:"+a.CurrentLogFilename)
+		default:
+			appenders = append(appenders, fmt.Sprintf("%T", a))
+		}
+	}
+	return fmt.Sprintf("%s %v", format, appenders)
+}
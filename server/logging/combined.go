@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+// CombinedFilter logs requests in NCSA combined log format:
+//
+//	%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+type CombinedFilter struct {
+	writer io.Writer
+}
+
+var _ filter.Filter = (*CombinedFilter)(nil)
+
+func NewCombinedFilter(writer io.Writer) *CombinedFilter {
+	return &CombinedFilter{writer: writer}
+}
+
+// Close closes the writer if it is an io.Closer.
+func (f *CombinedFilter) Close() error {
+	return closeWriter(f.writer)
+}
+
+func (f *CombinedFilter) Name() string {
+	return "logging"
+}
+
+func (f *CombinedFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	responseWriter := filter.NewResponseWriter(w)
+
+	start := now()
+	chain[0].ServeHTTP(responseWriter, r, chain[1:])
+
+	size := "-"
+	if responseWriter.Size() > 0 {
+		size = strconv.FormatInt(responseWriter.Size(), 10)
+	}
+	referer := r.Referer()
+	if referer == "" {
+		referer = "-"
+	}
+	userAgent := r.UserAgent()
+	if userAgent == "" {
+		userAgent = "-"
+	}
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	} else if username, _, ok := r.BasicAuth(); ok && username != "" {
+		user = username
+	}
+	// See Filter.ServeHTTP for not using fmt.Fprintf.
+	record := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"\n",
+		getRemoteAddr(r),
+		user,
+		start.Format(timeFormat),
+		r.Method,
+		r.RequestURI,
+		r.Proto,
+		responseWriter.Status(),
+		size,
+		referer,
+		userAgent,
+	)
+	f.writer.Write([]byte(record))
+}
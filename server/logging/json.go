@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+// jsonRecord is a request log entry in JSON format.
+type jsonRecord struct {
+	Time       string `json:"time"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Status     int    `json:"status"`
+	Duration   int64  `json:"duration"`
+	Bytes      int64  `json:"bytes"`
+	RemoteAddr string `json:"remoteAddr"`
+	UserAgent  string `json:"userAgent"`
+	RequestID  string `json:"requestId,omitempty"`
+}
+
+// JSONFilter logs each request as a single line JSON object.
+type JSONFilter struct {
+	writer io.Writer
+}
+
+var _ filter.Filter = (*JSONFilter)(nil)
+
+func NewJSONFilter(writer io.Writer) *JSONFilter {
+	return &JSONFilter{writer: writer}
+}
+
+// Close closes the writer if it is an io.Closer.
+func (f *JSONFilter) Close() error {
+	return closeWriter(f.writer)
+}
+
+func (f *JSONFilter) Name() string {
+	return "logging"
+}
+
+func (f *JSONFilter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	responseWriter := filter.NewResponseWriter(w)
+
+	start := now()
+	chain[0].ServeHTTP(responseWriter, r, chain[1:])
+	end := now()
+
+	record := jsonRecord{
+		Time:       start.Format(time.RFC3339),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     responseWriter.Status(),
+		Duration:   end.Sub(start).Nanoseconds() / int64(time.Millisecond),
+		Bytes:      responseWriter.Size(),
+		RemoteAddr: getRemoteAddr(r),
+		UserAgent:  r.UserAgent(),
+		RequestID:  r.Header.Get(xRequestID),
+	}
+	// A new buffer is allocated for each record as the writer might be
+	// asynchronous.
+	b, err := json.Marshal(&record)
+	if err != nil {
+		return
+	}
+	f.writer.Write(append(b, '\n'))
+}
@@ -4,11 +4,8 @@ Package logging provides a logger for HTTP requests as a filter.
 package logging
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -26,6 +23,14 @@ const (
 // For testing
 var now = time.Now
 
+// closeWriter closes the writer if it is an io.Closer.
+func closeWriter(w io.Writer) error {
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 type Filter struct {
 	writer io.Writer
 }
@@ -36,12 +41,17 @@ func NewFilter(writer io.Writer) *Filter {
 	return &Filter{writer: writer}
 }
 
+// Close closes the writer if it is an io.Closer.
+func (f *Filter) Close() error {
+	return closeWriter(f.writer)
+}
+
 func (f *Filter) Name() string {
 	return "logging"
 }
 
 func (f *Filter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
-	responseWriter := &responseWriter{writer: w, status: 200}
+	responseWriter := filter.NewResponseWriter(w)
 
 	start := now()
 	chain[0].ServeHTTP(responseWriter, r, chain[1:])
@@ -73,8 +83,8 @@ func (f *Filter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filte
 		r.Method,
 		r.RequestURI,
 		r.Proto,
-		responseWriter.status,
-		responseWriter.size,
+		responseWriter.Status(),
+		responseWriter.Size(),
 		referer,
 		userAgent,
 		responseTime,
@@ -92,34 +102,3 @@ func getRemoteAddr(r *http.Request) string {
 	}
 	return r.RemoteAddr
 }
-
-// responseWriter is a wrapper for http.ResponseWriter and store response status.
-type responseWriter struct {
-	writer http.ResponseWriter
-	status int
-	size   uint64
-}
-
-func (w *responseWriter) Header() http.Header {
-	return w.writer.Header()
-}
-
-func (w *responseWriter) Write(b []byte) (int, error) {
-	n, err := w.writer.Write(b)
-	if err == nil {
-		w.size += uint64(n)
-	}
-	return n, err
-}
-
-func (w *responseWriter) WriteHeader(status int) {
-	w.status = status
-	w.writer.WriteHeader(status)
-}
-
-func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	if hijacker, ok := w.writer.(http.Hijacker); ok {
-		return hijacker.Hijack()
-	}
-	return nil, nil, errors.New("accesslog: http.Hijack is not implemented")
-}
@@ -97,3 +97,63 @@ func TestResponseError(t *testing.T) {
 		t.Fatalf("unexpected access log %v", buf.String())
 	}
 }
+
+func TestJSONFilter(t *testing.T) {
+	var buf bytes.Buffer
+
+	builder := filter.NewChain()
+	builder.Add(NewJSONFilter(&buf))
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/items?id=1", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("User-Agent", "test")
+	chain.ServeHTTP(w, r)
+
+	expected := `{"time":"2015-01-14T01:02:03+07:00","method":"POST","path":"/items","status":201,"duration":0,"bytes":7,"remoteAddr":"127.0.0.1","userAgent":"test"}` + "\n"
+	if expected != buf.String() {
+		t.Fatalf("unexpected access log %v", buf.String())
+	}
+}
+
+func TestCombinedFilter(t *testing.T) {
+	var buf bytes.Buffer
+
+	builder := filter.NewChain()
+	builder.Add(NewCombinedFilter(&buf))
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/apache_pb.gif?a=1", nil)
+	r.RequestURI = "/apache_pb.gif?a=1"
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.SetBasicAuth("frank", "secret")
+	r.Header.Set("Referer", "http://www.example.com/start.html")
+	r.Header.Set("User-Agent", "Mozilla/4.08 [en] (Win98; I ;Nav)")
+	chain.ServeHTTP(w, r)
+
+	expected := `127.0.0.1 - frank [14/Jan/2015:01:02:03 +0700] "GET /apache_pb.gif?a=1 HTTP/1.1" 200 2 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"` + "\n"
+	if expected != buf.String() {
+		t.Fatalf("unexpected access log %v", buf.String())
+	}
+
+	// Empty body
+	buf.Reset()
+	chain = builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	r, _ = http.NewRequest("HEAD", "/", nil)
+	r.RequestURI = "/"
+	r.RemoteAddr = "127.0.0.1:1234"
+	chain.ServeHTTP(httptest.NewRecorder(), r)
+	expected = `127.0.0.1 - - [14/Jan/2015:01:02:03 +0700] "HEAD / HTTP/1.1" 204 - "-" "-"` + "\n"
+	if expected != buf.String() {
+		t.Fatalf("unexpected access log %v", buf.String())
+	}
+}
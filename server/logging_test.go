@@ -1,6 +1,13 @@
 package server
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/goburrow/gomelon/core"
@@ -29,6 +36,74 @@ func TestDefaultRequestLogFactory(t *testing.T) {
 	}
 }
 
+func TestJSONRequestLogFactory(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := DefaultRequestLogFactory{Format: "json"}
+	appender := logging.AppenderConfiguration{}
+	appender.SetValue(&logging.ConsoleAppenderFactory{})
+
+	factory.Appenders = []logging.AppenderConfiguration{
+		appender,
+	}
+
+	filter, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch filter.(type) {
+	case *slogging.JSONFilter:
+	default:
+		t.Fatalf("unexpected filter %#v", filter)
+	}
+}
+
+func TestCombinedRequestLogFactory(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := DefaultRequestLogFactory{Format: "combined"}
+	appender := logging.AppenderConfiguration{}
+	appender.SetValue(&logging.ConsoleAppenderFactory{})
+
+	factory.Appenders = []logging.AppenderConfiguration{
+		appender,
+	}
+
+	filter, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch filter.(type) {
+	case *slogging.CombinedFilter:
+	default:
+		t.Fatalf("unexpected filter %#v", filter)
+	}
+}
+
+func TestRequestLogFactoryInvalidFormat(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := DefaultRequestLogFactory{Format: "xml"}
+	_, err := factory.Build(env)
+	if err == nil || err.Error() != `server: unsupported request log format "xml"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRequestLogConfigurationTypes(t *testing.T) {
+	for name, format := range map[string]string{
+		"DefaultRequestLog": "",
+		"json":              "json",
+		"combined":          "combined",
+	} {
+		var config RequestLogConfiguration
+		if err := json.Unmarshal([]byte(`{"type":"`+name+`"}`), &config); err != nil {
+			t.Fatal(err)
+		}
+		factory, ok := config.Value().(*DefaultRequestLogFactory)
+		if !ok || factory.Format != format {
+			t.Fatalf("unexpected request log of %s: %#v", name, config.Value())
+		}
+	}
+}
+
 func TestNoRequestLogFactory(t *testing.T) {
 	env := core.NewEnvironment()
 	factory := DefaultRequestLogFactory{}
@@ -45,3 +120,130 @@ func TestNoRequestLogFactory(t *testing.T) {
 		t.Fatalf("unexpected filter %#v", filter)
 	}
 }
+
+func TestRequestLogTask(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &commonFactory{}
+	appender := logging.AppenderConfiguration{}
+	appender.SetValue(&logging.ConsoleAppenderFactory{})
+	factory.RequestLog.SetValue(&DefaultRequestLogFactory{
+		Appenders: []logging.AppenderConfiguration{appender},
+	})
+
+	f := &requestLogFilter{filter: &noRequestLog{}}
+	task := &requestLogTask{factory: factory, env: env, filter: f}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/requestlog", nil)
+	task.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+	if w.Body.String() != "Request log: common [console:stdout] -> common [console:stdout]\n" {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+	if _, ok := f.filter.(*slogging.Filter); !ok {
+		t.Fatalf("unexpected filter %#v", f.filter)
+	}
+}
+
+func TestRequestLogTaskChangeFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := core.NewEnvironment()
+	factory := &commonFactory{}
+	filter, err := factory.getRequestLog(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &requestLogFilter{filter: filter}
+	task := &requestLogTask{factory: factory, env: env, filter: f}
+
+	filename := filepath.Join(dir, "access.log")
+	body := `{"type":"combined","appenders":[{"type":"FileAppender","currentLogFilename":"` + filename + `"}]}`
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/tasks/requestlog", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		task.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+		}
+		if i == 0 && w.Body.String() != "Request log: none -> combined [file:"+filename+"]\n" {
+			t.Fatalf("unexpected body %v", w.Body.String())
+		}
+	}
+	if _, ok := f.filter.(*slogging.CombinedFilter); !ok {
+		t.Fatalf("unexpected filter %#v", f.filter)
+	}
+	// Invalid configuration does not change the request log.
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/requestlog", strings.NewReader(`{"type":"combined","format":"xml"}`))
+	r.Header.Set("Content-Type", "application/json")
+	task.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	if _, ok := f.filter.(*slogging.CombinedFilter); !ok {
+		t.Fatalf("unexpected filter %#v", f.filter)
+	}
+}
+
+// closingFilter records whether it is closed.
+type closingFilter struct {
+	noRequestLog
+	closed bool
+}
+
+func (f *closingFilter) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestRequestLogTaskClosesFilter(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &commonFactory{}
+	old := &closingFilter{}
+	f := &requestLogFilter{filter: old}
+	task := &requestLogTask{factory: factory, env: env, filter: f}
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/requestlog", nil)
+	task.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !old.closed {
+		t.Fatalf("old filter is not closed %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestRequestLogWriterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := core.NewEnvironment()
+	appender := logging.AppenderConfiguration{}
+	appender.SetValue(&logging.FileAppenderFactory{
+		CurrentLogFilename: filepath.Join(dir, "requests.log"),
+	})
+	w, err := buildRequestLogWriter(env, []logging.AppenderConfiguration{appender}, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := w.(*requestLogWriter)
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Stopped again by the lifecycle.
+	if err = writer.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.writers[0].Write([]byte("x")); err == nil {
+		t.Fatal("file is not closed")
+	}
+}
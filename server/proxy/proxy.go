@@ -0,0 +1,121 @@
+/*
+Package proxy provides a filter which restores the client address, external
+scheme and host of requests forwarded by trusted reverse proxies.
+*/
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+const (
+	filterName = "proxy"
+
+	xForwardedFor   = "X-Forwarded-For"
+	xForwardedProto = "X-Forwarded-Proto"
+	xForwardedHost  = "X-Forwarded-Host"
+)
+
+// Filter sets remote address of the request from X-Forwarded-For header and
+// scheme and host of the request URL from X-Forwarded-Proto and
+// X-Forwarded-Host headers. The headers are only honored when the request
+// comes from one of the trusted proxies, otherwise they could be spoofed
+// by clients.
+type Filter struct {
+	trusted []*net.IPNet
+}
+
+var _ filter.Filter = (*Filter)(nil)
+
+// NewFilter allocates and returns a new Filter. Trusted proxies are given
+// as IP addresses or CIDR blocks, e.g. "10.0.0.1" or "10.0.0.0/8".
+func NewFilter(trustedProxies []string) (*Filter, error) {
+	trusted, err := filter.ParseIPNets(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid trusted proxy: %v", err)
+	}
+	return &Filter{trusted: trusted}, nil
+}
+
+func (f *Filter) Name() string {
+	return filterName
+}
+
+func (f *Filter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filter.Filter) {
+	if f.isTrusted(r.RemoteAddr) {
+		r2 := new(http.Request)
+		*r2 = *r
+		if ip := f.clientIP(r.Header.Get(xForwardedFor)); ip != "" {
+			// Port of the proxy connection is kept.
+			_, port, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				port = "0"
+			}
+			r2.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		proto := firstValue(r.Header.Get(xForwardedProto))
+		host := firstValue(r.Header.Get(xForwardedHost))
+		if proto == "http" || proto == "https" || host != "" {
+			u := *r.URL
+			if proto == "http" || proto == "https" {
+				u.Scheme = proto
+			}
+			if host != "" {
+				u.Host = host
+			}
+			r2.URL = &u
+		}
+		r = r2
+	}
+	chain[0].ServeHTTP(w, r, chain[1:])
+}
+
+// clientIP returns the right-most address in X-Forwarded-For header which is
+// not a trusted proxy, or the left-most one if all of them are trusted.
+// It returns an empty string if an invalid address is reached first.
+func (f *Filter) clientIP(forwardedFor string) string {
+	if forwardedFor == "" {
+		return ""
+	}
+	hops := strings.Split(forwardedFor, ",")
+	var ip net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip = net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !f.containsIP(ip) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+// isTrusted returns true if the remote address is one of the trusted proxies.
+func (f *Filter) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && f.containsIP(ip)
+}
+
+// containsIP returns true if ip is in one of the trusted proxies.
+func (f *Filter) containsIP(ip net.IP) bool {
+	return filter.ContainsIP(f.trusted, ip)
+}
+
+// firstValue returns the first element of a comma-separated header value,
+// which is set by the proxy closest to the client.
+func firstValue(s string) string {
+	if idx := strings.Index(s, ","); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goburrow/gomelon/core"
+	"github.com/goburrow/gomelon/server/filter"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewFilter([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := filter.NewChain()
+	builder.Add(f)
+	var baseURL string
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseURL = core.BaseURL(r)
+	}))
+
+	tests := []struct {
+		remoteAddr string
+		proto      string
+		host       string
+		baseURL    string
+	}{
+		{"10.1.2.3:1234", "https", "example.com", "https://example.com"},
+		{"10.1.2.3:1234", "https, http", "example.com, proxy", "https://example.com"},
+		{"10.1.2.3:1234", "", "example.com:8443", "http://example.com:8443"},
+		{"10.1.2.3:1234", "ftp", "", "http://localhost:8080"},
+		{"[::1]:1234", "https", "", "https://localhost:8080"},
+		{"192.168.1.1:1234", "https", "example.com", "http://localhost:8080"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.Host = "localhost:8080"
+		r.RemoteAddr = test.remoteAddr
+		if test.proto != "" {
+			r.Header.Set(xForwardedProto, test.proto)
+		}
+		if test.host != "" {
+			r.Header.Set(xForwardedHost, test.host)
+		}
+		chain.ServeHTTP(httptest.NewRecorder(), r)
+		if baseURL != test.baseURL {
+			t.Fatalf("unexpected base URL %v for %+v", baseURL, test)
+		}
+	}
+}
+
+func TestFilterInvalidProxy(t *testing.T) {
+	for _, s := range []string{"localhost", "10.0.0.0/33"} {
+		if _, err := NewFilter([]string{s}); err == nil {
+			t.Fatalf("error expected for %v", s)
+		}
+	}
+}
+
+func TestFilterForwardedFor(t *testing.T) {
+	f, err := NewFilter([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := filter.NewChain()
+	builder.Add(f)
+	var remoteAddr string
+	chain := builder.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		expected     string
+	}{
+		// Trusted
+		{"10.1.2.3:1234", "1.2.3.4", "1.2.3.4:1234"},
+		{"10.1.2.3:1234", "6.6.6.6, 1.2.3.4, 10.0.0.2", "1.2.3.4:1234"},
+		{"10.1.2.3:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3:1234"},
+		{"[::1]:1234", "2001:db8::1", "[2001:db8::1]:1234"},
+		{"10.1.2.3:1234", "1.2.3.4, unknown", "10.1.2.3:1234"},
+		{"10.1.2.3:1234", "", "10.1.2.3:1234"},
+		// Untrusted
+		{"192.168.1.1:1234", "1.2.3.4", "192.168.1.1:1234"},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			r.Header.Set(xForwardedFor, test.forwardedFor)
+		}
+		chain.ServeHTTP(httptest.NewRecorder(), r)
+		if remoteAddr != test.expected {
+			t.Fatalf("unexpected remote address %v for %+v", remoteAddr, test)
+		}
+		if r.RemoteAddr != test.remoteAddr {
+			t.Fatalf("original request is modified: %v", r.RemoteAddr)
+		}
+	}
+}
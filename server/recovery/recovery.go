@@ -8,16 +8,21 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"strings"
 
 	"github.com/codahale/metrics"
 	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
 	"github.com/goburrow/gomelon/server/filter"
 )
 
 const (
 	filterName = "recovery"
-	stackSkip  = 4
-	stackMax   = 50
+
+	requestIDHeader = "X-Request-Id"
+
+	stackSkip = 5
+	stackMax  = 50
 )
 
 var (
@@ -30,14 +35,73 @@ func init() {
 	logger = gol.GetLogger("gomelon/server/recovery")
 }
 
+// levelNames are log levels supported in the configuration.
+var levelNames = map[string]gol.Level{
+	"OFF":   gol.LevelOff,
+	"ERROR": gol.LevelError,
+	"WARN":  gol.LevelWarn,
+	"INFO":  gol.LevelInfo,
+	"DEBUG": gol.LevelDebug,
+}
+
+// Factory is the configuration of the recovery filter.
+type Factory struct {
+	// Message is sent to the client when a panic is recovered. Default is
+	// "Internal Server Error".
+	Message string
+	// Levels are log levels of recovered values by their type names as
+	// printed by %T, e.g. "*url.Error": "WARN". Panics are logged at ERROR
+	// level by default.
+	Levels map[string]string
+}
+
+// Build creates a new Filter from the configuration.
+func (f *Factory) Build() (*Filter, error) {
+	filter := NewFilter()
+	if f.Message != "" {
+		filter.Message = f.Message
+	}
+	for typeName, levelName := range f.Levels {
+		level, ok := levelNames[strings.ToUpper(levelName)]
+		if !ok {
+			return nil, fmt.Errorf("recovery: unsupported level %s for %s", levelName, typeName)
+		}
+		filter.levels[typeName] = level
+	}
+	return filter, nil
+}
+
 // Filter handles panics.
 type Filter struct {
+	// Message is sent to the client when a panic is recovered. Details of
+	// the panic are only logged.
+	Message string
+	// levels contains log levels of recovered values by their type names.
+	levels map[string]gol.Level
 }
 
 var _ filter.Filter = (*Filter)(nil)
 
 func NewFilter() *Filter {
-	return &Filter{}
+	return &Filter{
+		Message: http.StatusText(http.StatusInternalServerError),
+		levels:  make(map[string]gol.Level),
+	}
+}
+
+// SetLevel sets log level for recovered values which have the same type
+// as the given value. Panics are logged at ERROR level by default.
+// SetLevel must be called before the filter is used.
+func (f *Filter) SetLevel(value interface{}, level gol.Level) {
+	f.levels[fmt.Sprintf("%T", value)] = level
+}
+
+// level returns log level for the recovered value.
+func (f *Filter) level(value interface{}) gol.Level {
+	if level, ok := f.levels[fmt.Sprintf("%T", value)]; ok {
+		return level
+	}
+	return gol.LevelError
 }
 
 func (f *Filter) Name() string {
@@ -48,13 +112,46 @@ func (f *Filter) ServeHTTP(w http.ResponseWriter, r *http.Request, chain []filte
 	defer func() {
 		if err := recover(); err != nil {
 			panics.Add()
-			logger.Error("%v\n%s", err, stack())
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			core.AddInternalMetric("recovery.panics", 1)
+			requestID := ""
+			if r != nil {
+				requestID = filter.RequestID(r.Context())
+			}
+			f.log(r, requestID, err)
+			if requestID != "" {
+				w.Header().Set(requestIDHeader, requestID)
+			}
+			core.WriteError(w, r, core.NewHTTPError(http.StatusInternalServerError, f.Message))
 		}
 	}()
 	chain[0].ServeHTTP(w, r, chain[1:])
 }
 
+// log prints the request, recovered value and stack trace.
+func (f *Filter) log(r *http.Request, requestID string, value interface{}) {
+	var format func(string, ...interface{})
+	switch f.level(value) {
+	case gol.LevelOff:
+		return
+	case gol.LevelAll, gol.LevelTrace, gol.LevelDebug:
+		format = logger.Debug
+	case gol.LevelInfo:
+		format = logger.Info
+	case gol.LevelWarn:
+		format = logger.Warn
+	default:
+		format = logger.Error
+	}
+	method, path := "-", "-"
+	if r != nil {
+		method, path = r.Method, r.URL.Path
+	}
+	if requestID == "" {
+		requestID = "-"
+	}
+	format("%s %s (request id %s): %v\n%s", method, path, requestID, value, stack())
+}
+
 func stack() []byte {
 	var buf bytes.Buffer
 
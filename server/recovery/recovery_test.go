@@ -1,6 +1,8 @@
 package recovery
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -47,3 +49,102 @@ func testFilter(t *testing.T, h http.Handler) {
 		t.Fatalf("unexpected body %v", w.Body.String())
 	}
 }
+
+func TestLevel(t *testing.T) {
+	f := NewFilter()
+	f.SetLevel(errors.New("recoverable"), gol.LevelWarn)
+
+	if f.level(errors.New("other")) != gol.LevelWarn {
+		t.Fatalf("unexpected level %v", f.level(errors.New("other")))
+	}
+	if f.level("panic") != gol.LevelError {
+		t.Fatalf("unexpected level %v", f.level("panic"))
+	}
+}
+
+type testLogger struct {
+	gol.Logger
+	messages []string
+}
+
+func (l *testLogger) Error(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestPanicLog(t *testing.T) {
+	defaultLogger := logger
+	defer func() { logger = defaultLogger }()
+	testLogger := &testLogger{Logger: defaultLogger}
+	logger = testLogger
+
+	f := NewFilter()
+	f.Message = "Something went wrong."
+	builder := filter.NewChain()
+	builder.Add(filter.NewRequestIDFilter())
+	builder.Add(f)
+	chain := builder.Build(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("secret")
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/items", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	chain.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError || strings.TrimSpace(w.Body.String()) != f.Message {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Request-Id") != "abc" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+	if len(testLogger.messages) != 1 {
+		t.Fatalf("unexpected messages %v", testLogger.messages)
+	}
+	message := testLogger.messages[0]
+	if !strings.HasPrefix(message, "POST /items (request id abc): secret\n! ") ||
+		!strings.Contains(message, "TestPanicLog") {
+		t.Fatalf("unexpected message %v", message)
+	}
+}
+
+func TestPanicJSON(t *testing.T) {
+	builder := filter.NewChain()
+	builder.Add(NewFilter())
+	chain := builder.Build(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("panic")
+	}))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	chain.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError ||
+		w.Body.String() != `{"status":500,"message":"Internal Server Error"}`+"\n" {
+		t.Fatalf("unexpected response %v %q", w.Code, w.Body.String())
+	}
+}
+
+func TestFactory(t *testing.T) {
+	factory := Factory{
+		Message: "Oops",
+		Levels:  map[string]string{"*errors.errorString": "warn", "string": "OFF"},
+	}
+	f, err := factory.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Message != "Oops" {
+		t.Fatalf("unexpected message %v", f.Message)
+	}
+	if f.level(errors.New("recoverable")) != gol.LevelWarn {
+		t.Fatalf("unexpected level %v", f.level(errors.New("recoverable")))
+	}
+	if f.level("panic") != gol.LevelOff {
+		t.Fatalf("unexpected level %v", f.level("panic"))
+	}
+	if f.level(1) != gol.LevelError {
+		t.Fatalf("unexpected level %v", f.level(1))
+	}
+	factory.Levels = map[string]string{"string": "FATAL"}
+	if _, err = factory.Build(); err == nil {
+		t.Fatal("error expected")
+	}
+}
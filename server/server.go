@@ -5,16 +5,21 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
 	"github.com/goburrow/gomelon/server/filter"
 	"github.com/goburrow/polytype"
-	"github.com/zenazn/goji/graceful"
 	"github.com/zenazn/goji/web"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -28,54 +33,54 @@ func init() {
 	polytype.Register("SimpleServer", func() interface{} {
 		return &SimpleFactory{
 			ApplicationContextPath: "/application",
-			AdminContextPath:       "/admin",
+			AdminContextPath:       defaultAdminContextPath,
 		}
 	})
 	polytype.Register("DefaultRequestLog", func() interface{} {
 		return &DefaultRequestLogFactory{}
 	})
-}
-
-// Connector utilizes graceful.Server.
-// Each connector has its own listener which will be closed when closing the
-// server it belongs to. SetHandler() must be called before listening.
-type Connector struct {
-	Type string `valid:"nonzero"`
-	Addr string
-
-	CertFile string
-	KeyFile  string
-
-	server *graceful.Server
-}
-
-// SetHandler setup the server with the given handler.
-func (connector *Connector) SetHandler(handler http.Handler) {
-	if connector.server == nil {
-		connector.server = &graceful.Server{}
-	}
-	connector.server.Handler = handler
-}
-
-// Listen creates and serves a listerner.
-func (connector *Connector) Listen() error {
-	connector.server.Addr = connector.Addr
-
-	switch connector.Type {
-	case "http":
-		return connector.server.ListenAndServe()
-	case "https":
-		return connector.server.ListenAndServeTLS(connector.CertFile, connector.KeyFile)
-	}
-	return fmt.Errorf("server: unsupported connector type %s", connector.Type)
+	polytype.Register("json", func() interface{} {
+		return &DefaultRequestLogFactory{Format: requestLogFormatJSON}
+	})
+	polytype.Register("combined", func() interface{} {
+		return &DefaultRequestLogFactory{Format: requestLogFormatCombined}
+	})
 }
 
 // Server implements Server interface. Each server can have multiple
 // connectors (listeners).
 type Server struct {
 	Connectors []*Connector
+	// ShutdownTimeout is the grace period for in-flight requests to complete
+	// when stopping. Contexts of remaining requests are cancelled and their
+	// connections are closed forcefully after the timeout. Zero means no
+	// timeout.
+	ShutdownTimeout time.Duration
+	// PreShutdown is called when the server starts shutting down, before
+	// connections are drained.
+	PreShutdown func()
+	// Signals trigger graceful shutdown while the server is running.
+	// DefaultSignals is used if it is nil.
+	Signals []os.Signal
+
+	mu  sync.Mutex
+	run *serverRun
 }
 
+// serverRun is the state of each Start so that a stopped server can be
+// started again.
+type serverRun struct {
+	// cancel cancels the base context of all requests.
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	// stopped is closed when connections have been drained.
+	stopped chan struct{}
+	err     error
+}
+
+// DefaultSignals are signals handled by servers by default.
+var DefaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
 var _ core.Server = (*Server)(nil)
 
 // NewServer allocates and returns a new Server.
@@ -83,19 +88,52 @@ func NewServer() *Server {
 	return &Server{}
 }
 
-// Start starts all connectors of the server.
+// Start starts all connectors of the server and blocks until they are
+// stopped by Stop or a signal.
 func (server *Server) Start() error {
 	logger := gol.GetLogger(loggerName)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	run := &serverRun{
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+	// Signals are only handled while the server is running.
+	signals := server.Signals
+	if signals == nil {
+		signals = DefaultSignals
+	}
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, signals...)
+	defer signal.Stop(signalChan)
 
-	// Handle SIGINT
-	graceful.HandleSignals()
-	graceful.PreHook(func() {
-		logger.Info("stopping")
-	})
-	graceful.PostHook(func() {
-		logger.Info("stopped")
-	})
-	defer graceful.Wait()
+	// Listeners are created before serving so that a failed one does not
+	// leave the others running. Stop waits until they are created.
+	server.mu.Lock()
+	server.run = run
+	for i, connector := range server.Connectors {
+		if err := connector.open(); err != nil {
+			server.mu.Unlock()
+			closeConnectors(server.Connectors[:i])
+			err = connector.wrapError(err)
+			logger.Error("%v", err)
+			return err
+		}
+		connector.httpServer.BaseContext = func(net.Listener) context.Context {
+			return ctx
+		}
+	}
+	server.mu.Unlock()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-signalChan:
+			server.Stop()
+		case <-done:
+		}
+	}()
 
 	errorChan := make(chan error, len(server.Connectors))
 	defer close(errorChan)
@@ -104,38 +142,103 @@ func (server *Server) Start() error {
 	defer wg.Wait()
 
 	for _, connector := range server.Connectors {
-		logger.Info("listening %s", connector.Addr)
+		logger.Info("listening %s", connector.LocalAddr())
 		wg.Add(1)
 		go func(c *Connector) {
 			defer wg.Done()
-			errorChan <- c.Listen()
+			if err := c.serve(); err != nil {
+				errorChan <- c.wrapError(err)
+			} else {
+				errorChan <- nil
+			}
 		}(connector)
 	}
 	for _ = range server.Connectors {
-		select {
-		case err := <-errorChan:
-			if err != nil {
-				graceful.ShutdownNow()
-				return err
+		if err := <-errorChan; err != nil {
+			logger.Error("%v", err)
+			for _, c := range server.Connectors {
+				c.httpServer.Close()
 			}
+			closeConnectors(server.Connectors)
+			return err
 		}
 	}
-	return nil
+	// Serving returns as soon as shutting down begins.
+	<-run.stopped
+	logger.Info("stopped")
+	return run.err
 }
 
-// Stop stops all running connectors of the server.
+// Stop stops all running connectors of the server. New connections are
+// refused and in-flight requests are given ShutdownTimeout to complete after
+// PreShutdown returns.
 func (server *Server) Stop() error {
-	graceful.Shutdown()
-	graceful.Wait()
-	return nil
+	server.mu.Lock()
+	run := server.run
+	server.mu.Unlock()
+	if run == nil {
+		closeConnectors(server.Connectors)
+		return nil
+	}
+	run.stopOnce.Do(func() {
+		run.err = server.shutdown(run)
+		close(run.stopped)
+	})
+	<-run.stopped
+	return run.err
+}
+
+// shutdown drains connections of all connectors.
+func (server *Server) shutdown(run *serverRun) error {
+	gol.GetLogger(loggerName).Info("stopping")
+	if server.PreShutdown != nil {
+		server.PreShutdown()
+	}
+	ctx := context.Background()
+	if server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, server.ShutdownTimeout)
+		defer cancel()
+	}
+	var wg sync.WaitGroup
+	for _, c := range server.Connectors {
+		wg.Add(1)
+		go func(c *Connector) {
+			defer wg.Done()
+			c.httpServer.Shutdown(ctx)
+		}(c)
+	}
+	wg.Wait()
+	var err error
+	if ctx.Err() != nil {
+		// Grace period is over.
+		run.cancel()
+		for _, c := range server.Connectors {
+			c.httpServer.Close()
+		}
+		err = fmt.Errorf("server: connections were not drained in %v", server.ShutdownTimeout)
+	}
+	closeConnectors(server.Connectors)
+	return err
+}
+
+// closeConnectors closes listeners of all given connectors.
+func closeConnectors(connectors []*Connector) {
+	for _, c := range connectors {
+		c.close()
+	}
 }
 
 // addConnectors adds a new connector to the server.
-func (server *Server) addConnectors(handler http.Handler, connectors []Connector) {
+func (server *Server) addConnectors(handler http.Handler, connectors []Connector) error {
 	for i, _ := range connectors {
 		connectors[i].SetHandler(handler)
+		if err := connectors[i].configure(); err != nil {
+			return err
+		}
 		server.Connectors = append(server.Connectors, &connectors[i])
 	}
+	return nil
 }
 
 // Handler handles HTTP requests.
@@ -144,18 +247,39 @@ type Handler struct {
 	ServeMux *web.Mux
 	// FilterChain is the builder for HTTP filters.
 	FilterChain filter.Chain
+	// MaxHandlers is the number of handlers expected to be registered.
+	// A warning is logged when it is exceeded. Zero means no limit.
+	MaxHandlers int
+	// Headers are added to every response before dispatching the request.
+	// Headers set by handlers override them.
+	Headers http.Header
 
-	pathPrefix string
+	pathPrefix  string
+	numHandlers int
 }
 
 // Handler implements gomelon.ServerHandler
 var _ core.ServerHandler = (*Handler)(nil)
+var _ core.FilterHandler = (*Handler)(nil)
 
 // NewHandler creates a new multiplexer if not provided.
 func NewHandler() *Handler {
-	return &Handler{
+	h := &Handler{
 		ServeMux: web.New(),
 	}
+	h.ServeMux.NotFound(methodNotAllowed)
+	return h
+}
+
+// methodNotAllowed responds 405 Method Not Allowed with Allow header if the
+// request path is registered for other methods, otherwise 404 Not Found.
+func methodNotAllowed(c web.C, w http.ResponseWriter, r *http.Request) {
+	if methods, ok := c.Env[web.ValidMethodsKey].([]string); ok && len(methods) > 0 {
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	http.NotFound(w, r)
 }
 
 // Handle registers the handler for the given pattern.
@@ -187,6 +311,46 @@ func (h *Handler) Handle(method, pattern string, handler interface{}) {
 		panic("server: unsupported method " + method)
 	}
 	f(pattern, handler)
+
+	h.numHandlers++
+	if h.MaxHandlers > 0 && h.numHandlers == h.MaxHandlers+1 {
+		gol.GetLogger(loggerName).Warn("number of handlers exceeds %d when registering %s %s%s",
+			h.MaxHandlers, method, h.pathPrefix, pattern)
+	}
+}
+
+// PathParam returns value of the named parameter in the path pattern the
+// request matched, e.g. "id" in "/users/:id". It returns an empty string if
+// the parameter does not exist.
+func PathParam(r *http.Request, name string) string {
+	if params, ok := r.Context().Value(pathParamsKey).(map[string]string); ok {
+		return params[name]
+	}
+	return ""
+}
+
+// AddFilter adds the filter.Filter to the end of the filter chain.
+func (h *Handler) AddFilter(f interface{}) error {
+	ff, ok := f.(filter.Filter)
+	if !ok {
+		return fmt.Errorf("server: unsupported filter %T", f)
+	}
+	h.FilterChain.Add(ff)
+	return nil
+}
+
+// InsertFilter inserts the filter.Filter before the filter with the given
+// name in the filter chain.
+func (h *Handler) InsertFilter(f interface{}, name string) error {
+	ff, ok := f.(filter.Filter)
+	if !ok {
+		return fmt.Errorf("server: unsupported filter %T", f)
+	}
+	if !h.FilterChain.Contains(name) {
+		return fmt.Errorf("server: filter %s not found", name)
+	}
+	h.FilterChain.Insert(ff, name)
+	return nil
 }
 
 // PathPrefix returns server root context path.
@@ -197,13 +361,32 @@ func (h *Handler) PathPrefix() string {
 // ServeHTTP strips path prefix in the request URL path.
 // This method is actually only used when path prefix is set
 // (i.e. simple server - the handler acts as subrouter).
+// Requests outside of the path prefix are not found.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if h.pathPrefix != "" {
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, h.pathPrefix)
+		path := strings.TrimPrefix(r.URL.Path, h.pathPrefix)
+		if len(path) == len(r.URL.Path) || (path != "" && path[0] != '/') {
+			http.NotFound(w, r)
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+		r.URL.Path = path
 	}
 	h.ServeMux.ServeHTTP(w, r)
 }
 
+// normalizeContextPath returns the context path with a leading slash and
+// without trailing slashes, e.g. "api/" becomes "/api". Root path is empty.
+func normalizeContextPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
 // Factory is an union of DefaultFactory and SimpleFactory.
 type Factory struct {
 	polytype.Type
@@ -217,3 +400,14 @@ func (factory *Factory) Build(environment *core.Environment) (core.Server, error
 	}
 	return nil, fmt.Errorf("server: unsupported server %#v", factory.Value())
 }
+
+// Validate checks the configuration of the server factory if it supports
+// validation.
+func (factory *Factory) Validate() error {
+	if v, ok := factory.Value().(interface {
+		Validate() error
+	}); ok {
+		return v.Validate()
+	}
+	return nil
+}
@@ -1,9 +1,22 @@
 package server
 
 import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/goburrow/gol"
 	"github.com/goburrow/gomelon/core"
+	"golang.org/x/net/context"
 )
 
 type stubFactory struct {
@@ -34,3 +47,365 @@ func TestInvalidFactory(t *testing.T) {
 		t.Fatal("error expected")
 	}
 }
+
+func newTestConnectors(n int) []*Connector {
+	connectors := make([]*Connector, n)
+	for i := range connectors {
+		connectors[i] = &Connector{
+			Type: "http",
+			Addr: "127.0.0.1:0",
+		}
+		connectors[i].SetHandler(http.NotFoundHandler())
+	}
+	return connectors
+}
+
+// waitGoroutines waits until number of goroutines drops to n.
+func waitGoroutines(t *testing.T, n int) {
+	for i := 0; i < 100; i++ {
+		if runtime.NumGoroutine() <= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("goroutines leaked: %d, expected %d", runtime.NumGoroutine(), n)
+}
+
+// initSignals starts the signal handling goroutine of the process, which
+// never exits.
+func initSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGTERM)
+	signal.Stop(c)
+}
+
+func TestServerStartFailure(t *testing.T) {
+	initSignals()
+	numGoroutines := runtime.NumGoroutine()
+
+	server := NewServer()
+	server.Connectors = newTestConnectors(50)
+	server.Connectors[25].Type = "ftp"
+	if err := server.Start(); err == nil {
+		t.Fatal("error expected")
+	}
+	for i, c := range server.Connectors[:25] {
+		if _, err := c.listener.Load().(net.Listener).Accept(); err == nil {
+			t.Fatalf("connector %d is not closed", i)
+		}
+	}
+	waitGoroutines(t, numGoroutines)
+}
+
+func TestServerStartStop(t *testing.T) {
+	initSignals()
+	numGoroutines := runtime.NumGoroutine()
+
+	server := NewServer()
+	server.Connectors = newTestConnectors(50)
+	errorChan := make(chan error, 1)
+	go func() {
+		errorChan <- server.Start()
+	}()
+	for _, c := range server.Connectors {
+		for i := 0; i < 100 && c.LocalAddr() == nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		resp, err := http.Get("http://" + c.LocalAddr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errorChan; err != nil {
+		t.Fatal(err)
+	}
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	waitGoroutines(t, numGoroutines)
+}
+
+func TestServerSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported")
+	}
+	// The test process is killed if the signal is not handled so the server
+	// is run in a separate process.
+	if os.Getenv("GOMELON_TEST_SIGNAL") != "1" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestServerSignal$")
+		cmd.Env = append(os.Environ(), "GOMELON_TEST_SIGNAL=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("unexpected error %v: %s", err, out)
+		}
+		return
+	}
+	server := NewServer()
+	server.Connectors = newTestConnectors(2)
+	errorChan := make(chan error, 1)
+	go func() {
+		errorChan <- server.Start()
+	}()
+	for _, c := range server.Connectors {
+		for i := 0; i < 100 && c.LocalAddr() == nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-errorChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server is not stopped")
+	}
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	handler := NewHandler()
+	handler.Handle("POST", "/tasks/gc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	}))
+	handler.Handle("PUT", "/tasks/gc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	}))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/tasks/gc", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "done" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/tasks/gc", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST, PUT" {
+		t.Fatalf("unexpected response %v %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest("GET", "/tasks/unknown", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unexpected code %v", w.Code)
+	}
+}
+
+func TestHandlerMaxHandlers(t *testing.T) {
+	var buf bytes.Buffer
+	logger := gol.GetLogger(loggerName).(*gol.DefaultLogger)
+	logger.SetAppender(gol.NewAppender(&buf))
+	defer logger.SetAppender(nil)
+
+	factory := &commonFactory{MaxHandlers: 2}
+	handler := factory.newHandler()
+	handler.pathPrefix = "/admin"
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler.Handle("GET", "/a", noop)
+	handler.Handle("GET", "/b", noop)
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected log: %s", buf.String())
+	}
+	handler.Handle("POST", "/c", noop)
+	handler.Handle("POST", "/d", noop)
+	logs := buf.String()
+	if !strings.Contains(logs, "number of handlers exceeds 2 when registering POST /admin/c") {
+		t.Fatalf("unexpected log: %s", logs)
+	}
+	// Warning is logged only once.
+	if strings.Contains(logs, "/admin/d") {
+		t.Fatalf("unexpected log: %s", logs)
+	}
+}
+
+func TestServerStartAddressInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	server := NewServer()
+	server.Connectors = []*Connector{&Connector{Type: "http", Addr: l.Addr().String()}}
+	server.Connectors[0].SetHandler(http.NotFoundHandler())
+	err = server.Start()
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	prefix := "server: http connector " + l.Addr().String() + ": "
+	if !strings.HasPrefix(err.Error(), prefix) || !strings.Contains(err.Error(), "address already in use") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestServerStopCancelsContext(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	server := NewServer()
+	server.ShutdownTimeout = 100 * time.Millisecond
+	errorChan := startTestServer(t, server, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		cancelled <- r.Context().Err()
+	}))
+	go http.Get("http://" + server.Connectors[0].LocalAddr().String() + "/")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request is not started")
+	}
+	// Contexts are only cancelled when the grace period is over.
+	if err := server.Stop(); err == nil {
+		t.Fatal("error expected")
+	}
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("context is not cancelled")
+	}
+	if err := <-errorChan; err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	server := NewServer()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.Context().Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	})
+	for i := 0; i < 2; i++ {
+		errorChan := startTestServer(t, server, handler)
+		resp, err := http.Get("http://" + server.Connectors[0].LocalAddr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %v", resp.StatusCode)
+		}
+		if err = server.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err = <-errorChan; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// startTestServer starts a server with one connector serving the handler and
+// waits until it is listening.
+func startTestServer(t *testing.T, server *Server, handler http.Handler) chan error {
+	server.Connectors = newTestConnectors(1)
+	server.Connectors[0].SetHandler(handler)
+	errorChan := make(chan error, 1)
+	go func() {
+		errorChan <- server.Start()
+	}()
+	c := server.Connectors[0]
+	for i := 0; i < 100 && c.LocalAddr() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.LocalAddr() == nil {
+		t.Fatal("server is not started")
+	}
+	return errorChan
+}
+
+func TestServerStopDrain(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := NewServer()
+	server.ShutdownTimeout = 5 * time.Second
+	errorChan := startTestServer(t, server, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			select {
+			case <-time.After(200 * time.Millisecond):
+			case <-r.Context().Done():
+				http.Error(w, "cancelled", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("done"))
+	}))
+	url := "http://" + server.Connectors[0].LocalAddr().String()
+	// Requests are still accepted by pre-shutdown hooks.
+	var hookErr error
+	server.PreShutdown = func() {
+		var res *http.Response
+		if res, hookErr = http.Get(url + "/"); hookErr == nil {
+			res.Body.Close()
+		}
+	}
+	type response struct {
+		code int
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		res.Body.Close()
+		responses <- response{code: res.StatusCode}
+	}()
+	<-started
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if hookErr != nil {
+		t.Fatalf("unexpected error in pre-shutdown hook %v", hookErr)
+	}
+	if res := <-responses; res.err != nil || res.code != http.StatusOK {
+		t.Fatalf("unexpected response %v %v", res.code, res.err)
+	}
+	if err := <-errorChan; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerStopForce(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server := NewServer()
+	server.ShutdownTimeout = 100 * time.Millisecond
+	startTestServer(t, server, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// Context is ignored.
+		<-release
+	}))
+	responses := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + server.Connectors[0].LocalAddr().String() + "/")
+		if err == nil {
+			res.Body.Close()
+		}
+		responses <- err
+	}()
+	<-started
+	err := server.Stop()
+	if err == nil || err.Error() != "server: connections were not drained in 100ms" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	select {
+	case err = <-responses:
+		if err == nil {
+			t.Fatal("error expected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection is not closed")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Package servertest provides utilities for testing gomelon servers.
+*/
+package servertest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/goburrow/gomelon/server"
+)
+
+const (
+	waitInterval = 10 * time.Millisecond
+	waitTimeout  = 5 * time.Second
+)
+
+// Server is a server listening on a system-assigned port of the loopback
+// interface.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:1234
+	URL string
+
+	server    *server.Server
+	connector *server.Connector
+	errorChan chan error
+}
+
+// NewServer starts and returns a new Server serving the given handler.
+func NewServer(handler http.Handler) (*Server, error) {
+	connector := &server.Connector{
+		Type: "http",
+		Addr: "127.0.0.1:0",
+	}
+	connector.SetHandler(handler)
+	s := &Server{
+		server:    server.NewServer(),
+		connector: connector,
+		errorChan: make(chan error, 1),
+	}
+	s.server.Connectors = []*server.Connector{connector}
+	go func() {
+		s.errorChan <- s.server.Start()
+	}()
+	for deadline := time.Now().Add(waitTimeout); time.Now().Before(deadline); {
+		if addr := connector.LocalAddr(); addr != nil {
+			s.URL = "http://" + addr.String()
+			return s, nil
+		}
+		select {
+		case err := <-s.errorChan:
+			if err == nil {
+				err = errors.New("servertest: server stopped")
+			}
+			return nil, err
+		case <-time.After(waitInterval):
+		}
+	}
+	return nil, errors.New("servertest: timed out starting server")
+}
+
+// Stop stops the server and waits until it exits.
+func (s *Server) Stop() error {
+	err := s.server.Stop()
+	if startErr := <-s.errorChan; err == nil {
+		err = startErr
+	}
+	return err
+}
+
+// VerifyShutdown checks the shutdown contract of the server: when stopping,
+// new connections are refused while in-flight requests are completed.
+func VerifyShutdown(t testing.TB) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s, err := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// In-flight request
+	type response struct {
+		body string
+		err  error
+	}
+	responseChan := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(s.URL)
+		if err != nil {
+			responseChan <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		responseChan <- response{string(b), err}
+	}()
+	<-started
+
+	stopChan := make(chan error, 1)
+	go func() {
+		stopChan <- s.Stop()
+	}()
+	// New connections are refused.
+	addr := s.connector.LocalAddr().String()
+	for deadline := time.Now().Add(waitTimeout); ; {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatal("servertest: server still accepts connections when stopping")
+		}
+		time.Sleep(waitInterval)
+	}
+	close(release)
+
+	resp := <-responseChan
+	if resp.err != nil {
+		t.Fatalf("servertest: in-flight request failed: %v", resp.err)
+	}
+	if resp.body != "done" {
+		t.Fatalf("servertest: unexpected response of in-flight request %q", resp.body)
+	}
+	if err = <-stopChan; err != nil {
+		t.Fatalf("servertest: stopping server failed: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package servertest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNewServer(t *testing.T) {
+	s, err := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(s.URL + "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "/test" {
+		t.Fatalf("unexpected response %s", b)
+	}
+	if err = s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyShutdown(t *testing.T) {
+	VerifyShutdown(t)
+}
+
+func TestVerifyShutdownRepeated(t *testing.T) {
+	// Each server shuts down independently.
+	for i := 0; i < 3; i++ {
+		VerifyShutdown(t)
+	}
+}
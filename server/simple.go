@@ -2,56 +2,112 @@ package server
 
 import (
 	"net/http"
+	"sort"
 
 	"github.com/goburrow/gomelon/core"
 )
 
-// SimpleFactory creates a single-connector server.
+const defaultAdminContextPath = "/admin"
+
+// SimpleFactory creates a single-connector server. Application and admin
+// handlers are served on the same connector under their context paths.
 type SimpleFactory struct {
 	commonFactory
 
 	ApplicationContextPath string `valid:"nonzero"`
-	AdminContextPath       string `valid:"nonzero"`
-	Connector              Connector
+	// AdminContextPath is the path prefix of admin handlers, default is "/admin".
+	AdminContextPath string
+	Connector        Connector
 }
 
 var _ core.ServerFactory = (*SimpleFactory)(nil)
 
+// Validate checks the configuration of the factory and its connector.
+func (factory *SimpleFactory) Validate() error {
+	problems := factory.commonFactory.validate()
+	if normalizeContextPath(factory.ApplicationContextPath) == "" {
+		problems = append(problems, "applicationContextPath is required")
+	}
+	adminPath := factory.adminContextPath()
+	if adminPath == "" {
+		problems = append(problems, "adminContextPath must not be root")
+	} else if adminPath == normalizeContextPath(factory.ApplicationContextPath) {
+		problems = append(problems, "adminContextPath must be different from applicationContextPath")
+	}
+	for _, p := range factory.Connector.validate() {
+		problems = append(problems, "connector: "+p)
+	}
+	return newValidationError(problems)
+}
+
 func (factory *SimpleFactory) Build(env *core.Environment) (core.Server, error) {
+	if err := factory.Validate(); err != nil {
+		return nil, err
+	}
 	// Both application and admin share same handler
-	appHandler := NewHandler()
-	appHandler.pathPrefix = factory.ApplicationContextPath
-	appHandler.ServeMux.Use(func(h http.Handler) http.Handler {
-		return appHandler.FilterChain.Build(h)
-	})
+	appHandler := factory.newAppHandler()
+	appHandler.pathPrefix = normalizeContextPath(factory.ApplicationContextPath)
 	env.Server.ServerHandler = appHandler
 	env.Server.AddResourceHandler(newResourceHandler(appHandler, env.Server))
 
-	adminHandler := NewHandler()
-	adminHandler.pathPrefix = factory.AdminContextPath
-	adminHandler.ServeMux.Use(func(h http.Handler) http.Handler {
-		return adminHandler.FilterChain.Build(h)
-	})
+	adminHandler := factory.newHandler()
+	adminHandler.pathPrefix = factory.adminContextPath()
 	env.Admin.ServerHandler = adminHandler
+	if err := factory.commonFactory.AddApplicationFilters(appHandler); err != nil {
+		return nil, err
+	}
+	factory.commonFactory.AddAdminFilters(adminHandler)
 
 	return factory.buildServer(env, appHandler, adminHandler)
 }
 
 func (factory *SimpleFactory) buildServer(env *core.Environment, handlers ...*Handler) (core.Server, error) {
-	handler := NewHandler()
-	handler.ServeMux.Use(func(h http.Handler) http.Handler {
-		return handler.FilterChain.Build(h)
+	handler := factory.newHandler()
+	// Sub routers. Longer prefixes are added first as routes are matched in
+	// order, e.g. "/app/admin" must not be served by "/app".
+	sort.SliceStable(handlers, func(i, j int) bool {
+		return len(handlers[i].pathPrefix) > len(handlers[j].pathPrefix)
 	})
-	// Sub routers
 	for _, h := range handlers {
 		handler.ServeMux.Handle(h.pathPrefix+"/*", h)
-		handler.ServeMux.Handle(h.pathPrefix, http.RedirectHandler(h.pathPrefix+"/", http.StatusMovedPermanently))
+		handler.ServeMux.Handle(h.pathPrefix, newRedirectHandler(h.pathPrefix+"/", http.StatusMovedPermanently))
 	}
 	// Only need filters in the root handler.
 	if err := factory.commonFactory.AddFilters(env, handler); err != nil {
 		return nil, err
 	}
-	server := NewServer()
-	server.addConnectors(handler.ServeMux, []Connector{factory.Connector})
+	server, err := factory.newServer(env)
+	if err != nil {
+		return nil, err
+	}
+	if err = server.addConnectors(factory.wrapHandler(env, handler.ServeMux), []Connector{factory.Connector}); err != nil {
+		return nil, err
+	}
+	if err = factory.configureAdmin(env, server); err != nil {
+		return nil, err
+	}
 	return server, nil
 }
+
+// adminContextPath returns the normalized admin context path or the default
+// if it is not set.
+func (factory *SimpleFactory) adminContextPath() string {
+	if factory.AdminContextPath == "" {
+		return defaultAdminContextPath
+	}
+	return normalizeContextPath(factory.AdminContextPath)
+}
+
+// redirectHandler redirects requests to the given path on the external URL.
+type redirectHandler struct {
+	path string
+	code int
+}
+
+func newRedirectHandler(path string, code int) *redirectHandler {
+	return &redirectHandler{path: path, code: code}
+}
+
+func (h *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, core.ExternalURL(r, h.path), h.code)
+}
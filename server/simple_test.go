@@ -1,6 +1,9 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/goburrow/gomelon/core"
@@ -8,7 +11,11 @@ import (
 
 func TestSimpleFactory(t *testing.T) {
 	env := core.NewEnvironment()
-	factory := &SimpleFactory{}
+	factory := &SimpleFactory{
+		ApplicationContextPath: "/application",
+		AdminContextPath:       "/admin",
+		Connector:              Connector{Type: "http", Addr: "127.0.0.1:0"},
+	}
 
 	s, err := factory.Build(env)
 	if err != nil {
@@ -24,3 +31,125 @@ func TestSimpleFactory(t *testing.T) {
 		t.Fatal("Admin.ServerHandler is nil")
 	}
 }
+
+func TestSimpleFactoryRedirect(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &SimpleFactory{
+		ApplicationContextPath: "/app",
+		AdminContextPath:       "/admin",
+		Connector:              Connector{Type: "http", Addr: "127.0.0.1:0"},
+	}
+	factory.TrustedProxies = []string{"10.0.0.1"}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := s.(*Server).Connectors[0].server.Handler
+
+	tests := []struct {
+		remoteAddr string
+		location   string
+	}{
+		{"10.0.0.1:1234", "https://example.com/admin/"},
+		{"10.0.0.2:1234", "/admin/"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/admin", nil)
+		r.RemoteAddr = test.remoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Forwarded-Host", "example.com")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != test.location {
+			t.Fatalf("unexpected response %v %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestSimpleFactoryAdminContextPath(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &SimpleFactory{
+		ApplicationContextPath: "/app",
+		Connector:              Connector{Type: "http", Addr: "127.0.0.1:0"},
+	}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Admin.ServerHandler.PathPrefix() != "/admin" {
+		t.Fatalf("unexpected admin path prefix %v", env.Admin.ServerHandler.PathPrefix())
+	}
+	env.Server.ServerHandler.Handle("GET", "/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	if err = env.SetStarting(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.SetStopped()
+	handler := s.(*Server).Connectors[0].server.Handler
+
+	data := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/admin/ping", http.StatusOK, "pong\n"},
+		{"/app/foo", http.StatusOK, "foo"},
+		{"/admin/foo", http.StatusNotFound, ""},
+		{"/app/ping", http.StatusNotFound, ""},
+	}
+	for _, d := range data {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", d.path, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != d.code || !strings.HasPrefix(w.Body.String(), d.body) {
+			t.Fatalf("unexpected response for %s: %v %q", d.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSimpleFactoryNestedContextPath(t *testing.T) {
+	env := core.NewEnvironment()
+	factory := &SimpleFactory{
+		ApplicationContextPath: "/app",
+		AdminContextPath:       "/app/admin",
+		Connector:              Connector{Type: "http", Addr: "127.0.0.1:0"},
+	}
+	s, err := factory.Build(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Server.ServerHandler.Handle("GET", "/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	if err = env.SetStarting(); err != nil {
+		t.Fatal(err)
+	}
+	defer env.SetStopped()
+	handler := s.(*Server).Connectors[0].server.Handler
+
+	for path, body := range map[string]string{"/app/admin/ping": "pong\n", "/app/foo": "foo"} {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", path, nil)
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), body) {
+			t.Fatalf("unexpected response for %s: %v %q", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSimpleFactoryValidateContextPath(t *testing.T) {
+	factory := &SimpleFactory{
+		ApplicationContextPath: "/admin",
+		Connector:              Connector{Type: "http", Addr: "127.0.0.1:0"},
+	}
+	err := factory.Validate()
+	if err == nil || err.Error() != "server: invalid configuration: adminContextPath must be different from applicationContextPath" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	factory.AdminContextPath = "/"
+	err = factory.Validate()
+	if err == nil || err.Error() != "server: invalid configuration: adminContextPath must not be root" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// alpnProtocols are supported ALPN protocols.
+var alpnProtocols = map[string]bool{
+	"h2":       true,
+	"http/1.1": true,
+	"http/1.0": true,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfiguration contains TLS settings of a https connector.
+type TLSConfiguration struct {
+	// MinVersion and MaxVersion are TLS versions, e.g. "1.2".
+	MinVersion string
+	MaxVersion string
+	// NextProtos are ALPN protocols advertised by the server, e.g. "h2" and
+	// "http/1.1". Default is "http/1.1".
+	NextProtos []string
+	// CipherSuites are names of enabled cipher suites as defined in
+	// crypto/tls, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	CipherSuites []string
+}
+
+// newTLSConfig returns default TLS config.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS10,
+		NextProtos: []string{"http/1.1"},
+	}
+}
+
+// build creates a new tls.Config from the configuration.
+func (c *TLSConfiguration) build() (*tls.Config, error) {
+	config := newTLSConfig()
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("server: unsupported TLS version %s", c.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if c.MaxVersion != "" {
+		version, ok := tlsVersions[c.MaxVersion]
+		if !ok {
+			return nil, fmt.Errorf("server: unsupported TLS version %s", c.MaxVersion)
+		}
+		config.MaxVersion = version
+	}
+	if len(c.NextProtos) > 0 {
+		for _, proto := range c.NextProtos {
+			if !alpnProtocols[proto] {
+				return nil, fmt.Errorf("server: unsupported ALPN protocol %s", proto)
+			}
+		}
+		config.NextProtos = append([]string(nil), c.NextProtos...)
+	}
+	if len(c.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, s := range tls.CipherSuites() {
+			suites[s.Name] = s.ID
+		}
+		for _, s := range tls.InsecureCipherSuites() {
+			suites[s.Name] = s.ID
+		}
+		config.CipherSuites = make([]uint16, len(c.CipherSuites))
+		for i, name := range c.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("server: unsupported cipher suite %s", name)
+			}
+			config.CipherSuites[i] = id
+		}
+	}
+	return config, nil
+}
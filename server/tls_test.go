@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+)
+
+func TestTLSConfiguration(t *testing.T) {
+	c := TLSConfiguration{
+		MinVersion:   "1.2",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}
+	config, err := c.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected min version %v", config.MinVersion)
+	}
+	if config.MaxVersion != 0 {
+		t.Fatalf("unexpected max version %v", config.MaxVersion)
+	}
+	if len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Fatalf("unexpected cipher suites %v", config.CipherSuites)
+	}
+}
+
+func TestTLSConfigurationInvalid(t *testing.T) {
+	c := TLSConfiguration{MinVersion: "3.0"}
+	if _, err := c.build(); err == nil {
+		t.Fatal("error expected")
+	}
+	c = TLSConfiguration{CipherSuites: []string{"TLS_UNKNOWN"}}
+	if _, err := c.build(); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestConnectorTLSConfig(t *testing.T) {
+	connector := &Connector{
+		Type: "https",
+		TLS:  TLSConfiguration{MinVersion: "1.2"},
+	}
+	connector.SetHandler(http.NotFoundHandler())
+	if err := connector.configure(); err != nil {
+		t.Fatal(err)
+	}
+	if connector.server.TLSConfig.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("unexpected min version %v", connector.server.TLSConfig.MinVersion)
+	}
+}
+
+func TestTLSConfigurationNextProtos(t *testing.T) {
+	c := TLSConfiguration{}
+	config, err := c.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.NextProtos) != 1 || config.NextProtos[0] != "http/1.1" {
+		t.Fatalf("unexpected next protos %v", config.NextProtos)
+	}
+	c.NextProtos = []string{"h2", "http/1.1"}
+	config, err = c.build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.NextProtos) != 2 || config.NextProtos[0] != "h2" {
+		t.Fatalf("unexpected next protos %v", config.NextProtos)
+	}
+	c.NextProtos = []string{"spdy/3"}
+	if _, err = c.build(); err == nil {
+		t.Fatal("error expected")
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationError contains all problems found in the server configuration.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "server: invalid configuration: " + strings.Join(e, "; ")
+}
+
+// newValidationError returns nil if there is no problem.
+func newValidationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return ValidationError(problems)
+}
+
+// validateDuration checks the value is empty or a non-negative duration.
+func validateDuration(name, value string) []string {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return []string{fmt.Sprintf("%s is not a valid duration: %q", name, value)}
+	}
+	if d < 0 {
+		return []string{fmt.Sprintf("%s must not be negative: %q", name, value)}
+	}
+	return nil
+}
+
+// validateConnectors checks all connectors and prefixes problems with their
+// position in the configuration.
+func validateConnectors(name string, connectors []Connector) []string {
+	var problems []string
+	for i := range connectors {
+		for _, p := range connectors[i].validate() {
+			problems = append(problems, fmt.Sprintf("%s[%d]: %s", name, i, p))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConnectorValidate(t *testing.T) {
+	connector := &Connector{Type: "http", Addr: ":8080"}
+	if err := connector.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	connector = &Connector{Type: "https", ReadTimeout: "-1s", IdleTimeout: "forever"}
+	err := connector.Validate()
+	expected := ValidationError{
+		"certFile is required for https",
+		"keyFile is required for https",
+		"addr is required",
+		`readTimeout must not be negative: "-1s"`,
+		`idleTimeout is not a valid duration: "forever"`,
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
+
+func TestDefaultFactoryValidate(t *testing.T) {
+	factory := &DefaultFactory{
+		AdminConnectors: []Connector{
+			Connector{Type: "http", Addr: ":8081"},
+			Connector{Type: "ftp", Addr: ":21"},
+		},
+	}
+	factory.ShutdownTimeout = "-30s"
+	err := factory.Validate()
+	expected := ValidationError{
+		`shutdownTimeout must not be negative: "-30s"`,
+		"at least one application connector is required",
+		`adminConnectors[1]: unsupported type "ftp"`,
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if _, err = factory.Build(nil); err == nil {
+		t.Fatal("error expected")
+	}
+	factory.ApplicationConnectors = []Connector{Connector{Type: "https", Addr: ":8443", CertFile: "cert", KeyFile: "key"}}
+	factory.AdminConnectors = factory.AdminConnectors[:1]
+	factory.ShutdownTimeout = "30s"
+	if err = factory.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSimpleFactoryValidate(t *testing.T) {
+	factory := &Factory{}
+	factory.SetValue(&SimpleFactory{AdminContextPath: "/admin"})
+	err := factory.Validate()
+	expected := ValidationError{
+		"applicationContextPath is required",
+		`connector: unsupported type ""`,
+		"connector: addr is required",
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+	if err.Error() != `server: invalid configuration: applicationContextPath is required; connector: unsupported type ""; connector: addr is required` {
+		t.Fatalf("unexpected error message %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// TimeoutWriter passes the response through to the given ResponseWriter
+// until TimeOut is called. Writes are discarded after that so a handler
+// which is still running can not interfere with the timeout response.
+type TimeoutWriter struct {
+	w http.ResponseWriter
+
+	mu          sync.Mutex
+	header      http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+var _ http.Flusher = (*TimeoutWriter)(nil)
+
+// NewTimeoutWriter allocates and returns a new TimeoutWriter.
+func NewTimeoutWriter(w http.ResponseWriter) *TimeoutWriter {
+	return &TimeoutWriter{
+		w:      w,
+		header: make(http.Header),
+	}
+}
+
+func (w *TimeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *TimeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, context.DeadlineExceeded
+	}
+	w.writeHeader(http.StatusOK)
+	return w.w.Write(b)
+}
+
+func (w *TimeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	w.writeHeader(code)
+}
+
+// writeHeader copies the header to the original ResponseWriter and sends it
+// if it has not been sent.
+func (w *TimeoutWriter) writeHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.w.Header()
+	for k, v := range w.header {
+		header[k] = v
+	}
+	w.w.WriteHeader(code)
+}
+
+// Flush sends buffered data to the client if the original ResponseWriter
+// supports it.
+func (w *TimeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// TimeOut stops passing writes through. It returns false if the response
+// has already been started, in which case no other response can be sent.
+func (w *TimeoutWriter) TimeOut() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	return !w.wroteHeader
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"io"
 	"sync"
 	"time"
@@ -10,6 +11,9 @@ import (
 
 var writerLogger gol.Logger
 
+// ErrBufferFull is returned by AsyncWriter when data is discarded.
+var ErrBufferFull = errors.New("util: writer buffer is full")
+
 func init() {
 	writerLogger = gol.GetLogger("gomelon/util/writer")
 }
@@ -18,6 +22,9 @@ func init() {
 type AsyncWriter struct {
 	// DrainTimeout is maximum duration before timing out flush a channel.
 	DrainTimeout time.Duration
+	// DiscardWhenFull drops data instead of blocking when the buffer of a
+	// writer is full.
+	DiscardWhenFull bool
 
 	writers []io.Writer
 	chans   []chan []byte
@@ -64,11 +71,24 @@ func (a *AsyncWriter) Stop() error {
 	return nil
 }
 
-// Write sends data to all writers and returns error if a channel if full.
+// Write sends data to all writers. If DiscardWhenFull is set, it returns
+// ErrBufferFull when the buffer of any writer is full, otherwise it blocks
+// until there is space in the buffer.
 func (a *AsyncWriter) Write(b []byte) (int, error) {
+	var err error
 	for _, c := range a.chans {
-		// FIXME: still blocked if channel buffer is full.
-		c <- b
+		if !a.DiscardWhenFull {
+			c <- b
+			continue
+		}
+		select {
+		case c <- b:
+		default:
+			err = ErrBufferFull
+		}
+	}
+	if err != nil {
+		return 0, err
 	}
 	return len(b), nil
 }
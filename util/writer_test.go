@@ -119,3 +119,29 @@ func TestAsyncWriterFull(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestAsyncWriterDiscardWhenFull(t *testing.T) {
+	buf := make(chanWriter)
+	// Not started so the buffer is not drained.
+	writer := NewAsyncWriter(2, buf)
+	writer.DiscardWhenFull = true
+
+	for i := 0; i < 2; i++ {
+		_, err := writer.Write([]byte("data"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := writer.Write([]byte("full"))
+	if err != ErrBufferFull {
+		t.Fatalf("unexpected error %v", err)
+	}
+	// Data are written in order.
+	writer.Start()
+	defer writer.Stop()
+	for i := 0; i < 2; i++ {
+		if b := <-buf; string(b) != "data" {
+			t.Fatalf("unexpected data %s", b)
+		}
+	}
+}
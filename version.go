@@ -0,0 +1,44 @@
+package gomelon
+
+import (
+	"fmt"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+const (
+	unknownVersion = "unknown"
+)
+
+// VersionCommand prints build information of the application which is set
+// in Bootstrap.BuildInfo.
+type VersionCommand struct {
+}
+
+var _ core.Command = (*VersionCommand)(nil)
+
+func (command *VersionCommand) Name() string {
+	return "version"
+}
+
+func (command *VersionCommand) Description() string {
+	return "prints the application version and build information"
+}
+
+func (command *VersionCommand) Run(bootstrap *core.Bootstrap) error {
+	var info core.BuildInfo
+	if bootstrap.BuildInfo != nil {
+		info = *bootstrap.BuildInfo
+	}
+	fmt.Fprintf(stdout, "Name: %s\nVersion: %s\nCommit: %s\nDate: %s\n",
+		bootstrap.Application.Name(),
+		orUnknown(info.Version), orUnknown(info.Commit), orUnknown(info.Date))
+	return nil
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return unknownVersion
+	}
+	return s
+}
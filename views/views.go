@@ -0,0 +1,126 @@
+/*
+Package views provides a bundle rendering html/template files.
+*/
+package views
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/goburrow/gol"
+	"github.com/goburrow/gomelon/core"
+)
+
+const (
+	defaultPattern = "*.html"
+
+	loggerName = "gomelon/views"
+)
+
+// Bundle loads templates in a directory at startup.
+type Bundle struct {
+	// Dir is the directory of templates.
+	Dir string
+	// Pattern matches template files in Dir. Default is "*.html".
+	Pattern string
+	// Development re-parses templates on each rendering.
+	Development bool
+
+	mu        sync.RWMutex
+	templates *template.Template
+	err       error
+}
+
+var _ core.Bundle = (*Bundle)(nil)
+
+// NewBundle allocates and returns a new Bundle for templates in dir.
+func NewBundle(dir string) *Bundle {
+	return &Bundle{
+		Dir:     dir,
+		Pattern: defaultPattern,
+	}
+}
+
+// Initialize parses all templates. Parse errors are returned by Run so
+// the application does not start.
+func (b *Bundle) Initialize(bootstrap *core.Bootstrap) {
+	if b.err = b.Load(); b.err != nil {
+		gol.GetLogger(loggerName).Error("%v", b.err)
+	}
+}
+
+func (b *Bundle) Run(conf interface{}, env *core.Environment) error {
+	return b.err
+}
+
+// Load parses all templates in the directory.
+func (b *Bundle) Load() error {
+	pattern := b.Pattern
+	if pattern == "" {
+		pattern = defaultPattern
+	}
+	templates, err := template.ParseGlob(filepath.Join(b.Dir, pattern))
+	if err != nil {
+		return fmt.Errorf("views: could not parse templates: %v", err)
+	}
+	b.mu.Lock()
+	b.templates = templates
+	b.mu.Unlock()
+	return nil
+}
+
+// Render executes the template with the given name and writes HTML to w.
+func (b *Bundle) Render(w http.ResponseWriter, name string, data interface{}) error {
+	if b.Development {
+		if err := b.Load(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return err
+		}
+	}
+	b.mu.RLock()
+	templates := b.templates
+	b.mu.RUnlock()
+	if templates == nil {
+		err := fmt.Errorf("views: templates are not loaded")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	// Response is buffered so that a failed execution does not write
+	// partial content.
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Respond writes data as JSON if the client prefers it according to Accept
+// header, otherwise renders the template with the given name.
+func (b *Bundle) Respond(w http.ResponseWriter, r *http.Request, name string, data interface{}) error {
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(data)
+	}
+	return b.Render(w, name, data)
+}
+
+// acceptsJSON returns true if application/json is accepted and text/html is
+// not listed before it.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	jsonIndex := strings.Index(accept, "application/json")
+	if jsonIndex < 0 {
+		return false
+	}
+	htmlIndex := strings.Index(accept, "text/html")
+	return htmlIndex < 0 || jsonIndex < htmlIndex
+}
@@ -0,0 +1,104 @@
+package views
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goburrow/gomelon/core"
+)
+
+type page struct {
+	Title string `json:"title"`
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundleRender(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "index.html", "<h1>{{.Title}}</h1>")
+
+	bundle := NewBundle(dir)
+	bundle.Initialize(core.NewBootstrap(nil))
+	if err = bundle.Run(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := &page{Title: "<gomelon>"}
+
+	w := httptest.NewRecorder()
+	if err = bundle.Render(w, "index.html", data); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != "<h1>&lt;gomelon&gt;</h1>" || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected response %v %v", w.Header(), w.Body.String())
+	}
+	// Negotiation
+	w = httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	if err = bundle.Respond(w, r, "index.html", data); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != `{"title":"\u003cgomelon\u003e"}`+"\n" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %v %v", w.Header(), w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9")
+	bundle.Respond(w, r, "index.html", data)
+	if w.Body.String() != "<h1>&lt;gomelon&gt;</h1>" {
+		t.Fatalf("unexpected response %v", w.Body.String())
+	}
+	// Unknown template
+	w = httptest.NewRecorder()
+	if err = bundle.Render(w, "missing.html", data); err == nil || w.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected response %v %v", err, w.Code)
+	}
+}
+
+func TestBundleParseError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "index.html", "{{.Title")
+
+	bundle := NewBundle(dir)
+	bundle.Initialize(core.NewBootstrap(nil))
+	if err = bundle.Run(nil, nil); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestBundleDevelopment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomelon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "index.html", "v1")
+
+	bundle := NewBundle(dir)
+	bundle.Development = true
+	bundle.Initialize(core.NewBootstrap(nil))
+	writeTemplate(t, dir, "index.html", "v2")
+
+	w := httptest.NewRecorder()
+	if err = bundle.Render(w, "index.html", nil); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != "v2" {
+		t.Fatalf("unexpected body %v", w.Body.String())
+	}
+}